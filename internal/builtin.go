@@ -2,7 +2,11 @@ package internal
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"strings"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 // BuiltInFuncs contains all the native functions predefined in the global environment.
@@ -16,6 +20,18 @@ var BuiltInFuncs = map[string]NativeFunc{
 	"inc": inc,
 	"dec": dec,
 
+	// Rationals
+	"numerator":   numerator,
+	"denominator": denominator,
+
+	// Conversion
+	"int->float": intToFloat,
+	"floor":      floor,
+	"ceil":       ceil,
+	"round":      round,
+	"char->int":  charToInt,
+	"int->char":  intToChar,
+
 	// Relational
 	">":  gt,
 	">=": ge,
@@ -27,247 +43,1672 @@ var BuiltInFuncs = map[string]NativeFunc{
 	// Logic
 	"not": not,
 
+	// Lists
+	"list":    list,
+	"cons":    cons,
+	"first":   first,
+	"rest":    rest,
+	"nth":     nth,
+	"count":   count,
+	"empty?":  isEmpty,
+	"append":  appendLists,
+	"reverse": reverse,
+	"map":     mapList,
+	"filter":  filter,
+	"reduce":  reduce,
+	"range":   rangeList,
+	"take":    take,
+	"drop":    drop,
+
+	// Functions
+	"apply":      apply,
+	"partial":    partial,
+	"comp":       comp,
+	"identity":   identity,
+	"constantly": constantly,
+	"juxt":       juxt,
+
+	// Vectors
+	"vector": vector,
+	"assoc":  assoc,
+	"conj":   conj,
+
+	// Maps
+	"hash-map":  hashMap,
+	"get":       get,
+	"dissoc":    dissoc,
+	"keys":      keys,
+	"vals":      vals,
+	"contains?": contains,
+	"merge":     merge,
+
+	// Sets
+	"hash-set":     hashSet,
+	"disj":         disj,
+	"union":        union,
+	"intersection": intersection,
+	"difference":   difference,
+	"subset?":      isSubset,
+
 	// Test
-	"bool?":   isBool,
-	"list?":   isList,
-	"neg?":    isNeg,
-	"nil?":    isNil,
-	"int?":    isInt,
-	"pos?":    isPos,
-	"string?": isString,
-	"symbol?": isSymbol,
-	"zero?":   isZero,
+	"bool?":    isBool,
+	"char?":    isChar,
+	"float?":   isFloat,
+	"list?":    isList,
+	"map?":     isMap,
+	"neg?":     isNeg,
+	"nil?":     isNil,
+	"int?":     isInt,
+	"keyword?": isKeyword,
+	"number?":  isNumber,
+	"pos?":     isPos,
+	"set?":     isSet,
+	"ratio?":   isRatio,
+	"regex?":   isRegex,
+	"string?":  isString,
+	"symbol?":  isSymbol,
+	"vector?":  isVector,
+	"zero?":    isZero,
+
+	// Strings
+	"str":           str,
+	"substring":     substring,
+	"split":         split,
+	"join":          join,
+	"upper":         upper,
+	"lower":         lower,
+	"trim":          trim,
+	"replace":       replace,
+	"index-of":      indexOf,
+	"starts-with?":  startsWith,
+	"ends-with?":    endsWith,
+	"string-length": stringLength,
+	"string->int":   stringToInt,
+	"int->string":   intToString,
+
+	// Regular expressions
+	"re-pattern": rePattern,
+	"re-find":    reFind,
+	"re-matches": reMatches,
+	"re-seq":     reSeq,
+	"re-replace": reReplace,
+
+	// Symbols
+	"gensym":  gensym,
+	"keyword": keyword,
 
 	// IO
-	"print":   print,
-	"println": println,
+	"format": format,
 }
 
-func add(args ...Value) Value {
-	res := Int(0)
+func add(args ...Value) (Value, error) {
+	return fold(args, Int(0), addNumbers)
+}
 
-	for _, arg := range args {
-		res += arg.(Int)
+func sub(args ...Value) (Value, error) {
+	if len(args) == 1 {
+		return subNumbers(Int(0), args[0])
 	}
+	return fold(args, Int(0), subNumbers)
+}
 
-	return res
+func mul(args ...Value) (Value, error) {
+	return fold(args, Int(1), mulNumbers)
 }
 
-func sub(args ...Value) Value {
+func div(args ...Value) (Value, error) {
+	if len(args) == 1 {
+		return divNumbers(Int(1), args[0])
+	}
+	return fold(args, Int(1), divNumbers)
+}
+
+// fold applies the operation to the accumulated result and each of the
+// arguments, starting with the first argument or with the identity if
+// there are none.
+func fold(args []Value, identity Value, op func(x, y Value) (Value, error)) (Value, error) {
 	if len(args) == 0 {
-		return Int(0)
-	} else if len(args) == 1 {
-		return Int(-args[0].(Int))
+		return identity, nil
 	}
 
-	res := args[0].(Int)
+	res, err := toNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
 
 	for _, arg := range args[1:] {
-		res -= arg.(Int)
+		if res, err = op(res, arg); err != nil {
+			return nil, err
+		}
 	}
 
-	return res
+	return res, nil
 }
 
-func mul(args ...Value) Value {
-	res := Int(1)
+func mod(args ...Value) (Value, error) {
+	if err := checkArity("mod", args, 2); err != nil {
+		return nil, err
+	}
+	return modNumbers(args[0], args[1])
+}
 
-	for _, arg := range args {
-		res *= arg.(Int)
+func inc(args ...Value) (Value, error) {
+	if err := checkArity("inc", args, 1); err != nil {
+		return nil, err
+	}
+	return addNumbers(args[0], Int(1))
+}
+
+func dec(args ...Value) (Value, error) {
+	if err := checkArity("dec", args, 1); err != nil {
+		return nil, err
 	}
+	return subNumbers(args[0], Int(1))
+}
 
-	return res
+func floor(args ...Value) (Value, error) {
+	return rounding("floor", args, math.Floor, floorRat)
 }
 
-func div(args ...Value) Value {
-	if len(args) == 0 {
-		return Int(1)
-	} else if len(args) == 1 {
-		return Int(1 / args[0].(Int))
+func ceil(args ...Value) (Value, error) {
+	return rounding("ceil", args, math.Ceil, ceilRat)
+}
+
+func round(args ...Value) (Value, error) {
+	return rounding("round", args, math.Round, roundRat)
+}
+
+// rounding converts the number to an integer using the given
+// rounding functions for floats and rationals.
+func rounding(name string, args []Value, fn func(float64) float64, ratFn func(*big.Rat) *big.Int) (Value, error) {
+	if err := checkArity(name, args, 1); err != nil {
+		return nil, err
 	}
 
-	res := args[0].(Int)
+	switch x := args[0].(type) {
+	case Int, BigInt:
+		return x, nil
+	case Ratio:
+		return NewBigInt(ratFn(x.r)), nil
+	case Float:
+		f := fn(float64(x))
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, NewError(EType, "cannot convert %s to int", x)
+		}
+		i, _ := big.NewFloat(f).Int(nil)
+		return NewBigInt(i), nil
+	default:
+		return nil, typeError("number", x)
+	}
+}
 
-	for _, arg := range args[1:] {
-		res /= arg.(Int)
+func intToFloat(args ...Value) (Value, error) {
+	if err := checkArity("int->float", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case Int, BigInt, Ratio:
+		return promote(x, levelFloat), nil
+	default:
+		return nil, typeError("rational", x)
+	}
+}
+
+func charToInt(args ...Value) (Value, error) {
+	if err := checkArity("char->int", args, 1); err != nil {
+		return nil, err
+	}
+	c, ok := args[0].(Char)
+	if !ok {
+		return nil, typeError("char", args[0])
+	}
+	return Int(c), nil
+}
+
+func intToChar(args ...Value) (Value, error) {
+	if err := checkArity("int->char", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case Int:
+		if x > utf8.MaxRune || !utf8.ValidRune(rune(x)) {
+			return nil, NewError(ERange, "invalid code point %d", x)
+		}
+		return Char(x), nil
+	case BigInt:
+		return nil, NewError(ERange, "invalid code point %s", x)
+	default:
+		return nil, typeError("int", x)
 	}
+}
 
-	return res
+func numerator(args ...Value) (Value, error) {
+	if err := checkArity("numerator", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case Int, BigInt:
+		return x, nil
+	case Ratio:
+		return NewBigInt(new(big.Int).Set(x.r.Num())), nil
+	default:
+		return nil, typeError("rational", x)
+	}
 }
 
-func mod(args ...Value) Value {
-	return Int(args[0].(Int) % args[1].(Int))
+func denominator(args ...Value) (Value, error) {
+	if err := checkArity("denominator", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case Int, BigInt:
+		return Int(1), nil
+	case Ratio:
+		return NewBigInt(new(big.Int).Set(x.r.Denom())), nil
+	default:
+		return nil, typeError("rational", x)
+	}
 }
 
-func inc(args ...Value) Value {
-	return args[0].(Int) + 1
+func gt(args ...Value) (Value, error) {
+	return compare(args, func(c int) bool { return c > 0 })
 }
 
-func dec(args ...Value) Value {
-	return args[0].(Int) - 1
+func ge(args ...Value) (Value, error) {
+	return compare(args, func(c int) bool { return c >= 0 })
 }
 
-func gt(args ...Value) Value {
+func eq(args ...Value) (Value, error) {
 	if len(args) == 0 {
-		return True
+		return True, nil
 	}
 
-	x := args[0].(Int)
+	x := args[0]
 
 	for _, arg := range args[1:] {
-		y := arg.(Int)
-		if x <= y {
-			return False
+		if !equals(x, arg) {
+			return False, nil
 		}
-		x = y
 	}
 
-	return True
+	return True, nil
+}
+
+func ne(args ...Value) (Value, error) {
+	res, err := eq(args...)
+	if err != nil {
+		return nil, err
+	}
+	return not(res)
+}
+
+func le(args ...Value) (Value, error) {
+	return compare(args, func(c int) bool { return c <= 0 })
+}
+
+func lt(args ...Value) (Value, error) {
+	return compare(args, func(c int) bool { return c < 0 })
 }
 
-func ge(args ...Value) Value {
+// compare returns whether the comparison of every consecutive
+// pair of arguments satisfies the given relation.
+func compare(args []Value, rel func(c int) bool) (Value, error) {
 	if len(args) == 0 {
-		return True
+		return True, nil
 	}
 
-	x := args[0].(Int)
+	x, err := toNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
 
-	for _, arg := range args[1:] {
-		y := arg.(Int)
-		if x < y {
-			return False
+	for _, y := range args[1:] {
+		c, ok, err := compareNumbers(x, y)
+		if err != nil {
+			return nil, err
+		}
+		// NaN is not ordered with respect to any number
+		if !ok || !rel(c) {
+			return False, nil
 		}
 		x = y
 	}
 
-	return True
+	return True, nil
 }
 
-func eq(args ...Value) Value {
-	if len(args) == 0 {
-		return True
+func not(args ...Value) (Value, error) {
+	if err := checkArity("not", args, 1); err != nil {
+		return nil, err
 	}
+	return NewBool(!truthy(args[0])), nil
+}
 
-	x := args[0]
+func isNil(args ...Value) (Value, error) {
+	if err := checkArity("nil?", args, 1); err != nil {
+		return nil, err
+	}
+	return NewBool(args[0] == nil), nil
+}
 
-	for _, arg := range args[1:] {
-		if !x.Equals(arg) {
-			return False
+func isZero(args ...Value) (Value, error) {
+	if err := checkArity("zero?", args, 1); err != nil {
+		return nil, err
+	}
+	c, ok, err := compareNumbers(args[0], Int(0))
+	if err != nil {
+		return nil, err
+	}
+	return NewBool(ok && c == 0), nil
+}
+
+func isPos(args ...Value) (Value, error) {
+	if err := checkArity("pos?", args, 1); err != nil {
+		return nil, err
+	}
+	c, ok, err := compareNumbers(args[0], Int(0))
+	if err != nil {
+		return nil, err
+	}
+	return NewBool(ok && c > 0), nil
+}
+
+func isNeg(args ...Value) (Value, error) {
+	if err := checkArity("neg?", args, 1); err != nil {
+		return nil, err
+	}
+	c, ok, err := compareNumbers(args[0], Int(0))
+	if err != nil {
+		return nil, err
+	}
+	return NewBool(ok && c < 0), nil
+}
+
+func isInt(args ...Value) (Value, error) {
+	if err := checkArity("int?", args, 1); err != nil {
+		return nil, err
+	}
+	switch args[0].(type) {
+	case Int, BigInt:
+		return True, nil
+	default:
+		return False, nil
+	}
+}
+
+func isFloat(args ...Value) (Value, error) {
+	if err := checkArity("float?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Float)
+	return NewBool(ok), nil
+}
+
+func isRatio(args ...Value) (Value, error) {
+	if err := checkArity("ratio?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Ratio)
+	return NewBool(ok), nil
+}
+
+func isNumber(args ...Value) (Value, error) {
+	if err := checkArity("number?", args, 1); err != nil {
+		return nil, err
+	}
+	return NewBool(isNumeric(args[0])), nil
+}
+
+func isBool(args ...Value) (Value, error) {
+	if err := checkArity("bool?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Bool)
+	return NewBool(ok), nil
+}
+
+func isString(args ...Value) (Value, error) {
+	if err := checkArity("string?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(String)
+	return NewBool(ok), nil
+}
+
+func isChar(args ...Value) (Value, error) {
+	if err := checkArity("char?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Char)
+	return NewBool(ok), nil
+}
+
+func isList(args ...Value) (Value, error) {
+	if err := checkArity("list?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(List)
+	return NewBool(ok), nil
+}
+
+func isVector(args ...Value) (Value, error) {
+	if err := checkArity("vector?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Vector)
+	return NewBool(ok), nil
+}
+
+func isMap(args ...Value) (Value, error) {
+	if err := checkArity("map?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Map)
+	return NewBool(ok), nil
+}
+
+func isSet(args ...Value) (Value, error) {
+	if err := checkArity("set?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Set)
+	return NewBool(ok), nil
+}
+
+func isKeyword(args ...Value) (Value, error) {
+	if err := checkArity("keyword?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Keyword)
+	return NewBool(ok), nil
+}
+
+func isRegex(args ...Value) (Value, error) {
+	if err := checkArity("regex?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Regex)
+	return NewBool(ok), nil
+}
+
+func isSymbol(args ...Value) (Value, error) {
+	if err := checkArity("symbol?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(Symbol)
+	return NewBool(ok), nil
+}
+
+func isEmpty(args ...Value) (Value, error) {
+	if err := checkArity("empty?", args, 1); err != nil {
+		return nil, err
+	}
+	n, err := length(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBool(n == 0), nil
+}
+
+func list(args ...Value) (Value, error) {
+	return NewList(args...), nil
+}
+
+func cons(args ...Value) (Value, error) {
+	if err := checkArity("cons", args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return nil, err
+	}
+	res := make(List, 0, len(l)+1)
+	res = append(res, args[0])
+	return append(res, l...), nil
+}
+
+func first(args ...Value) (Value, error) {
+	if err := checkArity("first", args, 1); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if l.IsEmpty() {
+		return nil, nil
+	}
+	return l[0], nil
+}
+
+func rest(args ...Value) (Value, error) {
+	if err := checkArity("rest", args, 1); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if l.IsEmpty() {
+		return NewList(), nil
+	}
+	return l[1:], nil
+}
+
+func nth(args ...Value) (Value, error) {
+	if err := checkArity("nth", args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := toIndex(args[1], len(l))
+	if err != nil {
+		return nil, err
+	}
+	return l[i], nil
+}
+
+func count(args ...Value) (Value, error) {
+	if err := checkArity("count", args, 1); err != nil {
+		return nil, err
+	}
+	n, err := length(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return Int(n), nil
+}
+
+// length returns the number of elements of the value, counting
+// collections without converting them into a list.
+func length(val Value) (int, error) {
+	switch x := val.(type) {
+	case String:
+		return utf8.RuneCountInString(string(x)), nil
+	case Vector:
+		return x.Len(), nil
+	case Map:
+		return x.Len(), nil
+	case Set:
+		return x.Len(), nil
+	default:
+		l, err := toList(x)
+		if err != nil {
+			return 0, err
 		}
+		return len(l), nil
 	}
+}
 
-	return True
+func vector(args ...Value) (Value, error) {
+	vals := make([]Value, len(args))
+	copy(vals, args)
+	return NewVector(vals...), nil
 }
 
-func ne(args ...Value) Value {
-	return not(eq(args...))
+func assoc(args ...Value) (Value, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, arityError("assoc", "a collection and key-value pairs", len(args))
+	}
+	switch coll := args[0].(type) {
+	case Vector:
+		for i := 1; i < len(args); i += 2 {
+			// The index can be the length to add a value at the end
+			n, err := toIndex(args[i], coll.Len()+1)
+			if err != nil {
+				return nil, err
+			}
+			coll = coll.Assoc(n, args[i+1])
+		}
+		return coll, nil
+	case nil, Map:
+		m, _ := toMap(coll)
+		for i := 1; i < len(args); i += 2 {
+			m = m.Assoc(args[i], args[i+1])
+		}
+		return m, nil
+	default:
+		return nil, typeError("map or vector", coll)
+	}
 }
 
-func le(args ...Value) Value {
+func conj(args ...Value) (Value, error) {
 	if len(args) == 0 {
-		return True
+		return nil, arityError("conj", "at least 1", len(args))
 	}
+	switch coll := args[0].(type) {
+	case Vector:
+		return coll.Conj(args[1:]...), nil
+	case Set:
+		return coll.Conj(args[1:]...), nil
+	case nil, List:
+		// Lists grow at the front, so the values end up reversed
+		l, _ := toList(coll)
+		res := make(List, 0, len(l)+len(args)-1)
+		for i := len(args) - 1; i > 0; i-- {
+			res = append(res, args[i])
+		}
+		return append(res, l...), nil
+	default:
+		return nil, typeError("collection", coll)
+	}
+}
 
-	x := args[0].(Int)
+func hashMap(args ...Value) (Value, error) {
+	if len(args)%2 != 0 {
+		return nil, arityError("hash-map", "key-value pairs", len(args))
+	}
+	return newMapFromPairs(args), nil
+}
 
-	for _, arg := range args[1:] {
-		y := arg.(Int)
-		if x > y {
-			return False
+func get(args ...Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, arityError("get", "2 or 3", len(args))
+	}
+	var def Value
+	if len(args) == 3 {
+		def = args[2]
+	}
+	switch coll := args[0].(type) {
+	case nil, Map:
+		m, _ := toMap(coll)
+		if val, ok := m.Get(args[1]); ok {
+			return val, nil
 		}
-		x = y
+		return def, nil
+	case Vector:
+		if i, err := toIndex(args[1], coll.Len()); err == nil {
+			return coll.Nth(i), nil
+		}
+		return def, nil
+	default:
+		return nil, typeError("map or vector", coll)
 	}
+}
 
-	return True
+func dissoc(args ...Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, arityError("dissoc", "at least 1", len(args))
+	}
+	m, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range args[1:] {
+		m = m.Dissoc(key)
+	}
+	return m, nil
+}
+
+func keys(args ...Value) (Value, error) {
+	if err := checkArity("keys", args, 1); err != nil {
+		return nil, err
+	}
+	m, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return m.Keys(), nil
+}
+
+func vals(args ...Value) (Value, error) {
+	if err := checkArity("vals", args, 1); err != nil {
+		return nil, err
+	}
+	m, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return m.Vals(), nil
+}
+
+func contains(args ...Value) (Value, error) {
+	if err := checkArity("contains?", args, 2); err != nil {
+		return nil, err
+	}
+	switch coll := args[0].(type) {
+	case nil, Map:
+		m, _ := toMap(coll)
+		_, ok := m.Get(args[1])
+		return NewBool(ok), nil
+	case Vector:
+		_, err := toIndex(args[1], coll.Len())
+		return NewBool(err == nil), nil
+	case Set:
+		return NewBool(coll.Contains(args[1])), nil
+	default:
+		return nil, typeError("map, set or vector", coll)
+	}
+}
+
+func merge(args ...Value) (Value, error) {
+	res := NewMap()
+	for _, arg := range args {
+		m, err := toMap(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range m.entries {
+			res.set(e.key, e.val)
+		}
+	}
+	return res, nil
+}
+
+func hashSet(args ...Value) (Value, error) {
+	return NewSet(args...), nil
 }
 
-func lt(args ...Value) Value {
+func disj(args ...Value) (Value, error) {
 	if len(args) == 0 {
-		return True
+		return nil, arityError("disj", "at least 1", len(args))
+	}
+	set, err := toSet(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, val := range args[1:] {
+		set = set.Disj(val)
+	}
+	return set, nil
+}
+
+func union(args ...Value) (Value, error) {
+	res := NewSet()
+	for _, arg := range args {
+		set, err := toSet(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range set.Elems() {
+			res.m.set(e, e)
+		}
 	}
+	return res, nil
+}
 
-	x := args[0].(Int)
+func intersection(args ...Value) (Value, error) {
+	return filterSets("intersection", args, func(val Value, set Set) bool {
+		return set.Contains(val)
+	})
+}
 
-	for _, arg := range args[1:] {
-		y := arg.(Int)
-		if x >= y {
-			return False
+func difference(args ...Value) (Value, error) {
+	return filterSets("difference", args, func(val Value, set Set) bool {
+		return !set.Contains(val)
+	})
+}
+
+// filterSets returns the elements of the first set that
+// satisfy the predicate for all the other sets.
+func filterSets(name string, args []Value, pred func(val Value, set Set) bool) (Value, error) {
+	if len(args) == 0 {
+		return nil, arityError(name, "at least 1", len(args))
+	}
+	sets := make([]Set, len(args))
+	for i, arg := range args {
+		set, err := toSet(arg)
+		if err != nil {
+			return nil, err
 		}
-		x = y
+		sets[i] = set
 	}
 
-	return True
+	res := NewSet()
+	for _, e := range sets[0].Elems() {
+		keep := true
+		for _, set := range sets[1:] {
+			if !pred(e, set) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			res.m.set(e, e)
+		}
+	}
+	return res, nil
 }
 
-func not(args ...Value) Value {
-	if args[0] == nil {
-		return True
+func isSubset(args ...Value) (Value, error) {
+	if err := checkArity("subset?", args, 2); err != nil {
+		return nil, err
+	}
+	x, err := toSet(args[0])
+	if err != nil {
+		return nil, err
+	}
+	y, err := toSet(args[1])
+	if err != nil {
+		return nil, err
 	}
-	return NewBool(args[0].Equals(False))
+	return NewBool(x.IsSubset(y)), nil
 }
 
-func isNil(args ...Value) Value {
-	return NewBool(args[0] == nil)
+func appendLists(args ...Value) (Value, error) {
+	res := NewList()
+	for _, arg := range args {
+		l, err := toList(arg)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l...)
+	}
+	return res, nil
 }
 
-func isZero(args ...Value) Value {
-	return NewBool(args[0].(Int) == 0)
+func reverse(args ...Value) (Value, error) {
+	if err := checkArity("reverse", args, 1); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := make(List, len(l))
+	for i, v := range l {
+		res[len(l)-1-i] = v
+	}
+	return res, nil
 }
 
-func isPos(args ...Value) Value {
-	return NewBool(args[0].(Int) > 0)
+func mapList(args ...Value) (Value, error) {
+	if len(args) < 2 {
+		return nil, arityError("map", "at least 2", len(args))
+	}
+	fn, err := toCallable(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// The function is applied to the elements of all the lists
+	// at the same position, until the shortest one is exhausted
+	lists := make([]List, len(args)-1)
+	n := -1
+	for i, arg := range args[1:] {
+		if lists[i], err = toList(arg); err != nil {
+			return nil, err
+		}
+		if n < 0 || len(lists[i]) < n {
+			n = len(lists[i])
+		}
+	}
+
+	res := make(List, n)
+	for i := range res {
+		fargs := make(List, len(lists))
+		for j, l := range lists {
+			fargs[j] = l[i]
+		}
+		if res[i], err = fn.Apply(fargs); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
-func isNeg(args ...Value) Value {
-	return NewBool(args[0].(Int) < 0)
+func filter(args ...Value) (Value, error) {
+	if err := checkArity("filter", args, 2); err != nil {
+		return nil, err
+	}
+	fn, err := toCallable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return nil, err
+	}
+	res := NewList()
+	for _, v := range l {
+		ok, err := fn.Apply(NewList(v))
+		if err != nil {
+			return nil, err
+		}
+		if truthy(ok) {
+			res = append(res, v)
+		}
+	}
+	return res, nil
 }
 
-func isInt(args ...Value) Value {
-	_, ok := args[0].(Int)
-	return NewBool(ok)
+func reduce(args ...Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, arityError("reduce", "2 or 3", len(args))
+	}
+	fn, err := toCallable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	l, err := toList(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	// Without an initial value the first element is used,
+	// and the function is called without arguments if empty
+	var acc Value
+	if len(args) == 3 {
+		acc = args[1]
+	} else if l.IsEmpty() {
+		return fn.Apply(NewList())
+	} else {
+		acc, l = l[0], l[1:]
+	}
+
+	for _, v := range l {
+		if acc, err = fn.Apply(NewList(acc, v)); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
 }
 
-func isBool(args ...Value) Value {
-	_, ok := args[0].(Bool)
-	return NewBool(ok)
+func rangeList(args ...Value) (Value, error) {
+	var start, end, step Value = Int(0), nil, Int(1)
+	switch len(args) {
+	case 1:
+		end = args[0]
+	case 2:
+		start, end = args[0], args[1]
+	case 3:
+		start, end, step = args[0], args[1], args[2]
+	default:
+		return nil, arityError("range", "1 to 3", len(args))
+	}
+
+	for _, arg := range args {
+		if _, err := toNumber(arg); err != nil {
+			return nil, err
+		}
+	}
+	if isZeroNumber(step) {
+		return nil, NewError(ERange, "step must not be zero")
+	}
+
+	// The values go towards the end in the direction of the step
+	dir, ok, err := compareNumbers(step, Int(0))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return NewList(), nil
+	}
+
+	res := NewList()
+	for x := start; ; {
+		c, ok, err := compareNumbers(x, end)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || c != -dir {
+			return res, nil
+		}
+		res = append(res, x)
+		if x, err = addNumbers(x, step); err != nil {
+			return nil, err
+		}
+	}
 }
 
-func isString(args ...Value) Value {
-	_, ok := args[0].(String)
-	return NewBool(ok)
+func take(args ...Value) (Value, error) {
+	n, l, err := splitArgs("take", args)
+	if err != nil {
+		return nil, err
+	}
+	return l[:n], nil
 }
 
-func isList(args ...Value) Value {
-	_, ok := args[0].(List)
-	return NewBool(ok)
+func drop(args ...Value) (Value, error) {
+	n, l, err := splitArgs("drop", args)
+	if err != nil {
+		return nil, err
+	}
+	return l[n:], nil
 }
 
-func isSymbol(args ...Value) Value {
-	_, ok := args[0].(Symbol)
-	return NewBool(ok)
+// splitArgs returns the arguments of take and drop, which are a
+// number of elements, limited to the length, and a list.
+func splitArgs(name string, args []Value) (int, List, error) {
+	if err := checkArity(name, args, 2); err != nil {
+		return 0, nil, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return 0, nil, err
+	}
+	switch n := args[0].(type) {
+	case Int:
+		if n < 0 {
+			return 0, l, nil
+		}
+		if int64(n) > int64(len(l)) {
+			return len(l), l, nil
+		}
+		return int(n), l, nil
+	case BigInt:
+		if n.i.Sign() < 0 {
+			return 0, l, nil
+		}
+		return len(l), l, nil
+	default:
+		return 0, nil, typeError("int", n)
+	}
+}
+
+func apply(args ...Value) (Value, error) {
+	if len(args) < 2 {
+		return nil, arityError("apply", "at least 2", len(args))
+	}
+	fn, err := toCallable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	// The last argument is a list with the rest of the arguments
+	l, err := toList(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	fargs := make(List, 0, len(args)-2+len(l))
+	fargs = append(fargs, args[1:len(args)-1]...)
+	return fn.Apply(append(fargs, l...))
 }
 
-func isEmpty(args ...Value) Value {
-	return NewBool(args[0].(List).IsEmpty())
+func partial(args ...Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, arityError("partial", "at least 1", len(args))
+	}
+	fn, err := toCallable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	bound := NewList(args[1:]...)
+	return NativeFunc(func(rest ...Value) (Value, error) {
+		fargs := make(List, 0, len(bound)+len(rest))
+		fargs = append(fargs, bound...)
+		return fn.Apply(append(fargs, rest...))
+	}), nil
 }
 
-func print(args ...Value) Value {
-	elems := make([]string, len(args))
+func comp(args ...Value) (Value, error) {
+	fns := make([]Callable, len(args))
 	for i, arg := range args {
-		if arg == nil {
-			elems[i] = "nil"
-		} else if v, ok := arg.(String); ok {
-			elems[i] = string(v)
-		} else {
-			elems[i] = fmt.Sprint(arg)
+		fn, err := toCallable(arg)
+		if err != nil {
+			return nil, err
 		}
+		fns[i] = fn
 	}
-	fmt.Print(strings.Join(elems, " "))
-	return nil
+	if len(fns) == 0 {
+		return NativeFunc(identity), nil
+	}
+	// The functions are applied from right to left
+	return NativeFunc(func(args ...Value) (Value, error) {
+		val, err := fns[len(fns)-1].Apply(args)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(fns) - 2; i >= 0; i-- {
+			if val, err = fns[i].Apply(NewList(val)); err != nil {
+				return nil, err
+			}
+		}
+		return val, nil
+	}), nil
+}
+
+func identity(args ...Value) (Value, error) {
+	if err := checkArity("identity", args, 1); err != nil {
+		return nil, err
+	}
+	return args[0], nil
+}
+
+func constantly(args ...Value) (Value, error) {
+	if err := checkArity("constantly", args, 1); err != nil {
+		return nil, err
+	}
+	val := args[0]
+	return NativeFunc(func(...Value) (Value, error) {
+		return val, nil
+	}), nil
+}
+
+func juxt(args ...Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, arityError("juxt", "at least 1", len(args))
+	}
+	fns := make([]Callable, len(args))
+	for i, arg := range args {
+		fn, err := toCallable(arg)
+		if err != nil {
+			return nil, err
+		}
+		fns[i] = fn
+	}
+	// The result is a vector with the result of each function
+	return NativeFunc(func(args ...Value) (Value, error) {
+		res := make([]Value, len(fns))
+		for i, fn := range fns {
+			val, err := fn.Apply(args)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = val
+		}
+		return NewVector(res...), nil
+	}), nil
+}
+
+func str(args ...Value) (Value, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		// Unlike when printed, nil is an empty string
+		if arg != nil {
+			b.WriteString(display(arg))
+		}
+	}
+	return NewString(b.String()), nil
+}
+
+func substring(args ...Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, arityError("substring", "2 or 3", len(args))
+	}
+	s, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	// The indexes are positions of runes, not bytes
+	runes := []rune(s)
+	start, err := toIndex(args[1], len(runes)+1)
+	if err != nil {
+		return nil, err
+	}
+	end := len(runes)
+	if len(args) == 3 {
+		if end, err = toIndex(args[2], len(runes)+1); err != nil {
+			return nil, err
+		}
+	}
+	if end < start {
+		return nil, NewError(ERange, "end %d before start %d", end, start)
+	}
+	return NewString(string(runes[start:end])), nil
+}
+
+func split(args ...Value) (Value, error) {
+	if err := checkArity("split", args, 2); err != nil {
+		return nil, err
+	}
+	s, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sep, err := toStr(args[1])
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(s, sep)
+	res := make(List, len(parts))
+	for i, p := range parts {
+		res[i] = NewString(p)
+	}
+	return res, nil
+}
+
+func join(args ...Value) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, arityError("join", "1 or 2", len(args))
+	}
+	sep := ""
+	if len(args) == 2 {
+		s, err := toStr(args[0])
+		if err != nil {
+			return nil, err
+		}
+		sep = s
+	}
+	l, err := toList(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]string, len(l))
+	for i, v := range l {
+		elems[i] = display(v)
+	}
+	return NewString(strings.Join(elems, sep)), nil
+}
+
+func upper(args ...Value) (Value, error) {
+	return mapString("upper", args, strings.ToUpper)
+}
+
+func lower(args ...Value) (Value, error) {
+	return mapString("lower", args, strings.ToLower)
+}
+
+func trim(args ...Value) (Value, error) {
+	return mapString("trim", args, strings.TrimSpace)
+}
+
+// mapString returns the string transformed by the given function.
+func mapString(name string, args []Value, fn func(string) string) (Value, error) {
+	if err := checkArity(name, args, 1); err != nil {
+		return nil, err
+	}
+	s, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewString(fn(s)), nil
+}
+
+func replace(args ...Value) (Value, error) {
+	if err := checkArity("replace", args, 3); err != nil {
+		return nil, err
+	}
+	strs, err := toStrs(args)
+	if err != nil {
+		return nil, err
+	}
+	return NewString(strings.ReplaceAll(strs[0], strs[1], strs[2])), nil
+}
+
+func indexOf(args ...Value) (Value, error) {
+	if err := checkArity("index-of", args, 2); err != nil {
+		return nil, err
+	}
+	strs, err := toStrs(args)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.Index(strs[0], strs[1])
+	if i < 0 {
+		return nil, nil
+	}
+	return Int(utf8.RuneCountInString(strs[0][:i])), nil
+}
+
+func startsWith(args ...Value) (Value, error) {
+	if err := checkArity("starts-with?", args, 2); err != nil {
+		return nil, err
+	}
+	strs, err := toStrs(args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBool(strings.HasPrefix(strs[0], strs[1])), nil
 }
 
-func println(args ...Value) Value {
-	print(args...)
-	fmt.Println()
+func endsWith(args ...Value) (Value, error) {
+	if err := checkArity("ends-with?", args, 2); err != nil {
+		return nil, err
+	}
+	strs, err := toStrs(args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBool(strings.HasSuffix(strs[0], strs[1])), nil
+}
+
+func stringLength(args ...Value) (Value, error) {
+	if err := checkArity("string-length", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return Int(utf8.RuneCountInString(s)), nil
+}
+
+// stringToInt returns the integer written in the string,
+// or nil if the string is not a valid integer.
+func stringToInt(args ...Value) (Value, error) {
+	if err := checkArity("string->int", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, nil
+	}
+	return NewBigInt(i), nil
+}
+
+func intToString(args ...Value) (Value, error) {
+	if err := checkArity("int->string", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case Int, BigInt:
+		return NewString(x.String()), nil
+	default:
+		return nil, typeError("int", x)
+	}
+}
+
+func rePattern(args ...Value) (Value, error) {
+	if err := checkArity("re-pattern", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	re, err := NewRegex(s)
+	if err != nil {
+		return nil, NewError(ESyntax, "invalid regex: %v", err)
+	}
+	return re, nil
+}
+
+// reFind returns the first match of the regex in the string or nil.
+func reFind(args ...Value) (Value, error) {
+	re, s, err := regexArgs("re-find", args)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	return re.match(s, loc), nil
+}
+
+// reMatches returns the match of the regex if it matches the whole string or nil.
+func reMatches(args ...Value) (Value, error) {
+	re, s, err := regexArgs("re-matches", args)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.anchored.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	return re.match(s, loc), nil
+}
+
+// reSeq returns a list with all the matches of the regex in the string.
+func reSeq(args ...Value) (Value, error) {
+	re, s, err := regexArgs("re-seq", args)
+	if err != nil {
+		return nil, err
+	}
+	res := NewList()
+	for _, loc := range re.re.FindAllStringSubmatchIndex(s, -1) {
+		res = append(res, re.match(s, loc))
+	}
+	return res, nil
+}
+
+// reReplace replaces all the matches of the regex in the string. The
+// replacement is either a string, where $1 or ${name} are replaced by
+// the groups, or a function called with each match.
+func reReplace(args ...Value) (Value, error) {
+	if err := checkArity("re-replace", args, 3); err != nil {
+		return nil, err
+	}
+	re, s, err := regexArgs("re-replace", args[:2])
+	if err != nil {
+		return nil, err
+	}
+
+	if repl, ok := args[2].(String); ok {
+		return NewString(re.re.ReplaceAllString(s, string(repl))), nil
+	}
+
+	fn, err := toCallable(args[2])
+	if err != nil {
+		return nil, typeError("string or function", args[2])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.re.FindAllStringSubmatchIndex(s, -1) {
+		val, err := fn.Apply(NewList(re.match(s, loc)))
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(display(val))
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+	return NewString(b.String()), nil
+}
+
+// regexArgs returns the arguments of the functions that
+// take a regex and the string to match against it.
+func regexArgs(name string, args []Value) (Regex, string, error) {
+	if err := checkArity(name, args, 2); err != nil {
+		return Regex{}, "", err
+	}
+	re, ok := args[0].(Regex)
+	if !ok {
+		return Regex{}, "", typeError("regex", args[0])
+	}
+	s, err := toStr(args[1])
+	if err != nil {
+		return Regex{}, "", err
+	}
+	return re, s, nil
+}
+
+// gensymCounter holds the number of symbols generated by gensym.
+var gensymCounter int64
+
+func gensym(args ...Value) (Value, error) {
+	if err := checkArity("gensym", args, 0); err != nil {
+		return nil, err
+	}
+	n := atomic.AddInt64(&gensymCounter, 1)
+	return NewSymbol(fmt.Sprintf("G__%d", n)), nil
+}
+
+func keyword(args ...Value) (Value, error) {
+	if err := checkArity("keyword", args, 1); err != nil {
+		return nil, err
+	}
+	switch x := args[0].(type) {
+	case String:
+		// The keyword could not be read back without a name
+		if x == "" {
+			return nil, NewError(ESyntax, "keyword name must not be empty")
+		}
+		return NewKeyword(string(x)), nil
+	case Symbol:
+		return NewKeyword(string(x)), nil
+	case *Keyword:
+		return x, nil
+	default:
+		return nil, typeError("string or symbol", x)
+	}
+}
+
+func format(args ...Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, arityError("format", "at least 1", len(args))
+	}
+	f, err := toStr(args[0])
+	if err != nil {
+		return nil, err
+	}
+	s, err := sprintf(f, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return NewString(s), nil
+}
+
+// outputFuncs returns the native functions that write
+// to the output port of the given environment.
+func outputFuncs(env *Enviroment) map[string]NativeFunc {
+	print := func(args ...Value) (Value, error) {
+		elems := make([]string, len(args))
+		for i, arg := range args {
+			elems[i] = display(arg)
+		}
+		return nil, env.write(strings.Join(elems, " "))
+	}
+
+	return map[string]NativeFunc{
+		"print": print,
+		"println": func(args ...Value) (Value, error) {
+			if _, err := print(args...); err != nil {
+				return nil, err
+			}
+			return nil, env.write("\n")
+		},
+		"printf": func(args ...Value) (Value, error) {
+			s, err := format(args...)
+			if err != nil {
+				return nil, err
+			}
+			return nil, env.write(string(s.(String)))
+		},
+	}
+}
+
+// checkArity returns an error unless exactly n arguments were given.
+func checkArity(name string, args []Value, n int) error {
+	if len(args) != n {
+		return arityError(name, fmt.Sprint(n), len(args))
+	}
 	return nil
 }
+
+// toList returns the value as a List or an error if it has another type.
+// The nil value is considered an empty list, strings are considered lists
+// of characters, maps lists of vectors with each key and value and sets
+// lists of their elements.
+func toList(val Value) (List, error) {
+	switch v := val.(type) {
+	case nil:
+		return NewList(), nil
+	case List:
+		return v, nil
+	case Vector:
+		return v.Elems(), nil
+	case String:
+		return stringChars(v), nil
+	case Map:
+		// Each entry is a vector of its key and value
+		entries := make(List, len(v.entries))
+		for i, e := range v.entries {
+			entries[i] = NewVector(e.key, e.val)
+		}
+		return entries, nil
+	case Set:
+		return v.Elems(), nil
+	default:
+		return nil, typeError("list", val)
+	}
+}
+
+// toMap returns the value as a Map or an error if it has another type.
+// The nil value is considered an empty map.
+func toMap(val Value) (Map, error) {
+	switch v := val.(type) {
+	case nil:
+		return NewMap(), nil
+	case Map:
+		return v, nil
+	default:
+		return Map{}, typeError("map", val)
+	}
+}
+
+// toStr returns the value as a Go string or an error if it is not a String.
+func toStr(val Value) (string, error) {
+	s, ok := val.(String)
+	if !ok {
+		return "", typeError("string", val)
+	}
+	return string(s), nil
+}
+
+// toStrs returns all the values as Go strings or an
+// error if any of them is not a String.
+func toStrs(vals []Value) ([]string, error) {
+	strs := make([]string, len(vals))
+	for i, val := range vals {
+		s, err := toStr(val)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+// display returns the representation of the value meant to be read by
+// people, where strings and characters are written without quoting.
+func display(val Value) string {
+	switch v := val.(type) {
+	case String:
+		return string(v)
+	case Char:
+		return string(rune(v))
+	default:
+		return toString(val)
+	}
+}
+
+// toCallable returns the value as a Callable or an error if it cannot be applied.
+func toCallable(val Value) (Callable, error) {
+	fn, ok := val.(Callable)
+	if !ok {
+		return nil, typeError("function", val)
+	}
+	return fn, nil
+}
+
+// truthy returns whether the value is considered true by the logic
+// operators, which is any value except nil and false.
+func truthy(val Value) bool {
+	return val != nil && !val.Equals(False)
+}
+
+// toSet returns the value as a Set or an error if it has another type.
+// The nil value is considered an empty set.
+func toSet(val Value) (Set, error) {
+	switch v := val.(type) {
+	case nil:
+		return NewSet(), nil
+	case Set:
+		return v, nil
+	default:
+		return Set{}, typeError("set", val)
+	}
+}
+
+// toIndex returns the value as an index into a sequence of the given length
+// or an error if it is not an integer or is out of range.
+func toIndex(val Value, length int) (int, error) {
+	i, ok := val.(Int)
+	if !ok {
+		if _, ok := val.(BigInt); !ok {
+			return 0, typeError("int", val)
+		}
+		return 0, NewError(ERange, "index %s not in [0, %d)", val, length)
+	}
+	if i < 0 || int64(i) >= int64(length) {
+		return 0, NewError(ERange, "index %d not in [0, %d)", i, length)
+	}
+	return int(i), nil
+}
+
+// equals returns whether both values are equal, considering nil values.
+func equals(x, y Value) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	return x.Equals(y)
+}
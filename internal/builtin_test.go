@@ -1,6 +1,9 @@
 package internal
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 type funcTestCase struct {
 	args     []Value
@@ -9,8 +12,10 @@ type funcTestCase struct {
 
 func testFunc(t *testing.T, fn NativeFunc, cases []funcTestCase) {
 	for i, c := range cases {
-		found := fn(c.args...)
-		if found != c.expected {
+		found, err := fn(c.args...)
+		if err != nil {
+			t.Errorf("%d: err: %v", i, err)
+		} else if found != c.expected {
 			t.Errorf("%d: expected = %v, found %v", i, c.expected, found)
 		}
 	}
@@ -35,14 +40,61 @@ func TestSub(t *testing.T) {
 func TestDiv(t *testing.T) {
 	testFunc(t, div, []funcTestCase{
 		{[]Value{}, Int(1)},
-		{[]Value{Int(4)}, Int(0)},
 		{[]Value{Int(4), Int(2)}, Int(2)},
+		{[]Value{Int(8), Int(2), Int(2)}, Int(2)},
 	})
 }
 
+func TestDivByZero(t *testing.T) {
+	cases := [][]Value{
+		{Int(0)},
+		{Int(4), Int(0)},
+		{Int(4), Int(2), Int(0)},
+	}
+
+	for i, args := range cases {
+		_, err := div(args...)
+		if e, ok := err.(*Error); !ok || e.Kind != EDivisionByZero {
+			t.Errorf("%d: expected division by zero, found %v", i, err)
+		}
+	}
+}
+
 func TestMod(t *testing.T) {
 	testFunc(t, mod, []funcTestCase{
 		{[]Value{Int(5), Int(2)}, Int(1)},
+		{[]Value{Float(5.5), Int(2)}, Float(1.5)},
+	})
+}
+
+func TestFloor(t *testing.T) {
+	testFunc(t, floor, []funcTestCase{
+		{[]Value{Int(1)}, Int(1)},
+		{[]Value{Float(1.5)}, Int(1)},
+		{[]Value{Float(-1.5)}, Int(-2)},
+	})
+}
+
+func TestCeil(t *testing.T) {
+	testFunc(t, ceil, []funcTestCase{
+		{[]Value{Int(1)}, Int(1)},
+		{[]Value{Float(1.5)}, Int(2)},
+		{[]Value{Float(-1.5)}, Int(-1)},
+	})
+}
+
+func TestRound(t *testing.T) {
+	testFunc(t, round, []funcTestCase{
+		{[]Value{Int(1)}, Int(1)},
+		{[]Value{Float(1.4)}, Int(1)},
+		{[]Value{Float(1.5)}, Int(2)},
+		{[]Value{Float(-1.5)}, Int(-2)},
+	})
+}
+
+func TestIntToFloat(t *testing.T) {
+	testFunc(t, intToFloat, []funcTestCase{
+		{[]Value{Int(1)}, Float(1)},
 	})
 }
 
@@ -159,6 +211,21 @@ func TestIsInt(t *testing.T) {
 	})
 }
 
+func TestIsFloat(t *testing.T) {
+	testFunc(t, isFloat, []funcTestCase{
+		{[]Value{Float(1)}, True},
+		{[]Value{Int(1)}, False},
+	})
+}
+
+func TestIsNumber(t *testing.T) {
+	testFunc(t, isNumber, []funcTestCase{
+		{[]Value{Int(1)}, True},
+		{[]Value{Float(1)}, True},
+		{[]Value{String("s")}, False},
+	})
+}
+
 func TestIsBool(t *testing.T) {
 	testFunc(t, isBool, []funcTestCase{
 		{[]Value{True}, True},
@@ -193,3 +260,27 @@ func TestIsEmpty(t *testing.T) {
 		{[]Value{NewList(Int(1))}, False},
 	})
 }
+
+func TestOutput(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected string
+	}{
+		{`(print "a" 1 \b nil)`, "a 1 b nil"},
+		{`(println "a" "b")`, "a b\n"},
+		{`(printf "%03d|%-3s|%v\n" 7 "a" "a")`, "007|a  |\"a\"\n"},
+	}
+
+	for i, c := range cases {
+		var buf bytes.Buffer
+		env := NewEnviroment()
+		env.SetOutput(&buf)
+
+		if _, err := Eval(c.s, env); err != nil {
+			t.Fatalf("%d: err: %v", i, err)
+		}
+		if c.expected != buf.String() {
+			t.Errorf("%d: expected = %q, found %q", i, c.expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Char is a single Unicode character.
+type Char rune
+
+func NewChar(r rune) Char {
+	return Char(r)
+}
+
+func (c Char) Eval(env *Enviroment) (Value, error) {
+	return c, nil
+}
+
+// String returns the character as a literal that can be read back, using
+// its name for whitespace and its code point for non-printable runes.
+func (c Char) String() string {
+	for name, r := range charNames {
+		if rune(c) == r {
+			return `\` + name
+		}
+	}
+	if !unicode.IsPrint(rune(c)) {
+		return fmt.Sprintf("\\u{%x}", rune(c))
+	}
+	return `\` + string(rune(c))
+}
+
+func (c Char) Equals(val Value) bool {
+	if v, ok := val.(Char); ok {
+		return c == v
+	}
+	return false
+}
+
+// charNames contains the characters that can be written by name.
+var charNames = map[string]rune{
+	"newline": '\n',
+	"space":   ' ',
+	"tab":     '\t',
+	"return":  '\r',
+}
+
+// stringChars returns the characters of the string.
+func stringChars(s String) List {
+	chars := NewList()
+	for _, r := range string(s) {
+		chars = append(chars, Char(r))
+	}
+	return chars
+}
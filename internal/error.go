@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorKind indicates the category of an error.
+type ErrorKind int
+
+const (
+	EUnknown ErrorKind = iota
+	ESyntax
+	EType
+	EArity
+	EDivisionByZero
+	EUnboundSymbol
+	ERange
+)
+
+var errorKinds = [...]string{
+	EUnknown:        "error",
+	ESyntax:         "syntax error",
+	EType:           "type error",
+	EArity:          "arity error",
+	EDivisionByZero: "division by zero",
+	EUnboundSymbol:  "unbound symbol",
+	ERange:          "out of range",
+}
+
+func (k ErrorKind) String() string {
+	if k >= 0 && int(k) < len(errorKinds) {
+		return errorKinds[k]
+	}
+	return errorKinds[0]
+}
+
+// Error represents an error raised during the evaluation of a program.
+type Error struct {
+	Kind ErrorKind
+	Pos  Pos
+	Msg  string
+	// Trace contains the functions being applied when the error was
+	// raised, from the innermost to the outermost.
+	Trace []Frame
+}
+
+// NewError creates a new Error of the given kind with a formatted message.
+func NewError(kind ErrorKind, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	msg := e.Kind.String()
+	if e.Msg != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Msg)
+	}
+	if e.Pos.IsValid() {
+		msg = fmt.Sprintf("%s: %s", e.Pos, msg)
+	}
+	return msg
+}
+
+// Traceback returns the error message followed by the
+// functions being applied when the error was raised.
+func (e *Error) Traceback() string {
+	lines := []string{e.Error()}
+	for _, frame := range e.Trace {
+		lines = append(lines, "  "+frame.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// syntaxErrorf returns a syntax error found at the given position of the source.
+func syntaxErrorf(pos Pos, format string, args ...interface{}) *Error {
+	err := NewError(ESyntax, format, args...)
+	err.Pos = pos
+	return err
+}
+
+// typeError returns an error indicating that a value of the
+// expected type was required but another was found.
+func typeError(expected string, found Value) *Error {
+	return NewError(EType, "expected %s, found %s", expected, typeName(found))
+}
+
+// arityError returns an error indicating that a function was called
+// with the wrong number of arguments.
+func arityError(name string, expected string, found int) *Error {
+	return NewError(EArity, "wrong number of arguments to %s: expected %s, found %d", name, expected, found)
+}
+
+// unboundError returns an error indicating that the symbol is not bound
+// in the environment, suggesting the closest visible symbol if any.
+func unboundError(sym Symbol, env *Enviroment) *Error {
+	if name := suggest(string(sym), env.Symbols()); name != "" {
+		return NewError(EUnboundSymbol, "%s, did you mean %s?", sym, name)
+	}
+	return NewError(EUnboundSymbol, "%s", sym)
+}
+
+// suggest returns the candidate closest to the given name or an empty
+// string if none of them is close enough to be considered a typo.
+func suggest(name string, candidates []string) string {
+	best := ""
+	bestDist := len([]rune(name))/3 + 1
+
+	for _, c := range candidates {
+		if d := distance(name, c); d < bestDist {
+			best = c
+			bestDist = d
+		}
+	}
+
+	return best
+}
+
+// distance returns the Damerau-Levenshtein distance between two strings,
+// counting a transposition of adjacent runes as a single edit.
+func distance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+
+	d := make([][]int, len(x)+1)
+	for i := range d {
+		d[i] = make([]int, len(y)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(x)][len(y)]
+}
+
+func minInt(x int, ys ...int) int {
+	for _, y := range ys {
+		if y < x {
+			x = y
+		}
+	}
+	return x
+}
+
+// typeName returns the name of the type of the value as seen by Slip programs.
+func typeName(val Value) string {
+	switch val.(type) {
+	case nil:
+		return "nil"
+	case Int, BigInt:
+		return "int"
+	case Ratio:
+		return "ratio"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	case Char:
+		return "char"
+	case Regex:
+		return "regex"
+	case Symbol:
+		return "symbol"
+	case *Keyword:
+		return "keyword"
+	case List:
+		return "list"
+	case Vector:
+		return "vector"
+	case Map:
+		return "map"
+	case Set:
+		return "set"
+	case NativeFunc, *Func:
+		return "function"
+	case *Macro:
+		return "macro"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
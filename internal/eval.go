@@ -1,48 +1,249 @@
 package internal
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
 // Eval evaluates the Slip program represented as a string
 // on the given environment, returning the value of the last expression.
 func Eval(s string, env *Enviroment) (Value, error) {
-	values, err := Parse(string(s))
+	return EvalFile("", s, env)
+}
+
+// EvalFile evaluates the contents of the named source file on the given
+// environment, returning the value of the last expression.
+func EvalFile(filename string, s string, env *Enviroment) (Value, error) {
+	values, err := parseFile(filename, s, env.spans)
 	if err != nil {
 		return nil, err
 	}
 
 	var out Value
 	for _, value := range values {
-		out = value.Eval(env)
+		if out, err = eval(value, env); err != nil {
+			return nil, err
+		}
 	}
 
 	return out, nil
 }
 
+// eval evaluates the expression on the given environment.
+//
+// Instead of recursing, the expressions in tail position are evaluated
+// by looping, so tail calls run on constant space.
+func eval(expr Value, env *Enviroment) (Value, error) {
+	return evalForm(expr, env, false)
+}
+
+// evalForm evaluates the expression like eval does. The expression can be
+// in tail position of a loop body, which is the only place recur can be in.
+func evalForm(expr Value, env *Enviroment, loopTail bool) (Value, error) {
+	stack := env.stack
+	depth := len(stack.frames)
+	defer stack.truncate(depth)
+
+	// pos is the position of the innermost form being evaluated
+	var pos Pos
+
+	for {
+		if expr == nil {
+			return nil, nil
+		}
+
+		l, ok := expr.(List)
+		if !ok {
+			val, err := expr.Eval(env)
+			if err != nil {
+				return nil, annotate(err, pos, stack)
+			}
+			return val, nil
+		}
+
+		if l.IsEmpty() {
+			return nil, nil
+		}
+
+		if p, ok := env.spans.PosOf(l); ok {
+			pos = p
+		}
+
+		if sym, ok := l[0].(Symbol); ok && sym == "recur" && !loopTail && env.inLoop() {
+			return nil, annotate(NewError(ESyntax, "recur must be in tail position"), pos, stack)
+		}
+
+		next, err := l.step(env)
+		if err != nil {
+			return nil, annotate(err, pos, stack)
+		}
+
+		if !next.tail {
+			return next.val, nil
+		}
+
+		if next.fn != nil {
+			// The body of the function is not part of the loop
+			loopTail = false
+
+			// A tail call replaces the frame of the current function
+			frame := Frame{Name: next.fn.Name(), Pos: pos}
+			if len(stack.frames) > depth {
+				stack.frames[len(stack.frames)-1] = frame
+			} else {
+				stack.push(frame)
+			}
+		}
+
+		expr, env = next.expr, next.env
+	}
+}
+
+// step is the outcome of evaluating a single form: either the final value
+// or an expression in tail position left to evaluate on an environment.
+type step struct {
+	val  Value
+	tail bool
+	expr Value
+	env  *Enviroment
+	// fn is the function called when the expression is its body.
+	fn *Func
+}
+
+func done(val Value) step {
+	return step{val: val}
+}
+
+func tail(expr Value, env *Enviroment) step {
+	return step{tail: true, expr: expr, env: env}
+}
+
+// annotate sets the position and the call stack where
+// the error was raised, unless they are already known.
+func annotate(err error, pos Pos, stack *CallStack) error {
+	if e, ok := err.(*Error); ok {
+		if !e.Pos.IsValid() {
+			e.Pos = pos
+		}
+		if e.Trace == nil {
+			e.Trace = stack.Frames()
+		}
+	}
+	return err
+}
+
 type Enviroment struct {
 	symbols map[string]Value
 	parent  *Enviroment
+	stack   *CallStack
+	// spans contains the positions of the lists parsed
+	// in the environment and is shared by its children.
+	spans Spans
+	// loop indicates whether the scope was created by a loop form.
+	loop bool
+	// output is the port where print, println and printf write,
+	// which is only set on the root environment.
+	output io.Writer
 }
 
 func NewEnviroment() *Enviroment {
-	env := &Enviroment{symbols: make(map[string]Value)}
+	env := &Enviroment{symbols: make(map[string]Value), stack: &CallStack{}, spans: Spans{}, output: os.Stdout}
+
+	env.Define(NewSymbol("nil"), nil)
 
 	for name, fn := range BuiltInFuncs {
 		env.Define(NewSymbol(name), fn)
 	}
 
+	for name, fn := range outputFuncs(env) {
+		env.Define(NewSymbol(name), fn)
+	}
+
+	if _, err := EvalFile("prelude", prelude, env); err != nil {
+		panic(fmt.Errorf("failed to evaluate prelude: %v", err))
+	}
+
 	return env
 }
 
 func NewChildEnviroment(parent *Enviroment) *Enviroment {
-	return &Enviroment{symbols: make(map[string]Value), parent: parent}
+	return &Enviroment{symbols: make(map[string]Value), parent: parent, stack: parent.stack, spans: parent.spans}
 }
 
 func (e *Enviroment) Define(sym Symbol, val Value) {
 	e.symbols[string(sym)] = val
 }
 
-func (e *Enviroment) Resolve(sym Symbol) Value {
-	val := e.symbols[string(sym)]
-	if val == nil && e.parent != nil {
+// Set updates the value bound to the symbol in the closest scope,
+// returning false if the symbol is not bound.
+func (e *Enviroment) Set(sym Symbol, val Value) bool {
+	for env := e; env != nil; env = env.parent {
+		if _, ok := env.symbols[string(sym)]; ok {
+			env.symbols[string(sym)] = val
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the value bound to the symbol in the closest scope and
+// whether it was found.
+func (e *Enviroment) Resolve(sym Symbol) (Value, bool) {
+	val, ok := e.symbols[string(sym)]
+	if !ok && e.parent != nil {
 		return e.parent.Resolve(sym)
 	}
-	return val
+	return val, ok
+}
+
+// SetOutput sets the port where print, println and printf write,
+// which is the standard output by default.
+func (e *Enviroment) SetOutput(w io.Writer) {
+	e.root().output = w
+}
+
+// write writes the string to the output port.
+func (e *Enviroment) write(s string) error {
+	if _, err := io.WriteString(e.root().output, s); err != nil {
+		return NewError(EUnknown, "failed to write output: %v", err)
+	}
+	return nil
+}
+
+// root returns the outermost scope of the environment.
+func (e *Enviroment) root() *Enviroment {
+	for e.parent != nil {
+		e = e.parent
+	}
+	return e
+}
+
+// inLoop returns whether the scope is nested in a loop form.
+func (e *Enviroment) inLoop() bool {
+	for env := e; env != nil; env = env.parent {
+		if env.loop {
+			return true
+		}
+	}
+	return false
+}
+
+// Symbols returns the names of all the symbols visible from this scope.
+func (e *Enviroment) Symbols() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+
+	for env := e; env != nil; env = env.parent {
+		for name := range env.symbols {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
 }
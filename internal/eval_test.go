@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -20,15 +21,44 @@ func TestEval(t *testing.T) {
 
 		{"(do (defn sum (x y) (+ x y)) (sum 1 2))", "3"},
 
+		{"(do (defmacro unless2 (test x) (list (quote if) test nil x)) (unless2 false 1))", "1"},
+		{"(do (defmacro unless2 (test x) (list (quote if) test nil x)) (unless2 true 1))", "<nil>"},
+		{"(do (defmacro my-if (test x y) (list (quote if) test x y)) (defn f (n) (my-if (= n 0) 0 (f (- n 1)))) (f 100000))", "0"},
+		{"(loop ((i 0)) (when (< i 10) (recur (inc i))))", "<nil>"},
+		{"(loop ((i 0)) (cond ((< i 10) (recur (inc i))) (true i)))", "10"},
+
 		{"(do \"hello\" \"world\")", "\"world\""},
 
 		{"((fn (x y) (+ x y)) 1 2)", "3"},
+		{"((fn (x (y 2)) (+ x y)) 1)", "3"},
+		{"((fn (x (y 2)) (+ x y)) 1 3)", "4"},
+		{"((fn (x (y (+ x 1))) (+ x y)) 1)", "3"},
+		{"((fn (x &rest xs) xs) 1)", "()"},
+		{"((fn (x &rest xs) xs) 1 2 3)", "(2 3)"},
+		{"((fn (x (y 2) &rest xs) (+ x y)) 1)", "3"},
+		{"((fn (x (y 2) &rest xs) xs) 1 2 3 4)", "(3 4)"},
 
 		{"(if true \"hello\")", "\"hello\""},
 		{"(if false \"hello\")", "<nil>"},
 		{"(if false \"hello\" \"world\")", "\"world\""},
 
 		{"(let ((x 1) (y 2)) (+ x y))", "3"},
+		{"(let ((x (+ 1 2))) x)", "3"},
+
+		{"(loop ((i 0)) (if (< i 10) (recur (inc i)) i))", "10"},
+		{"(loop ((i 0) (acc 1)) (if (= i 5) acc (recur (inc i) (* acc 2))))", "32"},
+		{"(loop ((i 0)) (let ((j (inc i))) (if (> j 100000) i (recur j))))", "100000"},
+		{"(loop ((i 0) (n 0)) (if (< i 3) (recur (inc i) (+ n (loop ((j 0)) (if (< j i) (recur (inc j)) j)))) n))", "3"},
+		{"(loop () 1 2)", "2"},
+		{"(loop ((i 0)) (if (< i 3) (recur (inc i)) `(recur ~i)))", "(recur 3)"},
+		{"(do (def n 0) (defmacro m (x) (set! n (inc n)) x) (loop ((i 0)) (m i)) n)", "1"},
+		{"(loop ((i 0)) (if (< i 3) (recur (inc i)) `(a `(recur ~(b ~i)))))", "(a (quasiquote (recur (unquote (b 3)))))"},
+
+		{"(do (def x 1) (set! x 2) x)", "2"},
+		{"(do (def x 1) (let ((y 1)) (set! x 2)) x)", "2"},
+		{"(do (def x 1) (let ((x 1)) (set! x 2)) x)", "1"},
+		{"(do (defn counter () (let ((n 0)) (fn () (set! n (inc n)) n))) (def c (counter)) (c) (c) (c))", "3"},
+		{"(do (def acc 0) (loop ((i 1)) (when (<= i 10) (set! acc (+ acc i)) (recur (inc i)))) acc)", "55"},
 
 		{"(or true)", "true"},
 		{"(or true \"hello\")", "true"},
@@ -36,7 +66,58 @@ func TestEval(t *testing.T) {
 		{"(or false false nil)", "<nil>"},
 
 		{"(quote (+ 1 2))", "(+ 1 2)"},
-		// {"'(+ 1 2)", "(+ 1 2)"},
+		{"'(+ 1 2)", "(+ 1 2)"},
+
+		{"`(+ 1 2)", "(+ 1 2)"},
+		{"`(+ 1 ~(+ 1 1))", "(+ 1 2)"},
+		{"`(+ 1 ,(+ 1 1))", "(+ 1 2)"},
+		{"`(+ ~@(list 1 2) 3)", "(+ 1 2 3)"},
+		{"`(+ ~@(list) 3)", "(+ 3)"},
+		{"`(a (b ~(+ 1 1)))", "(a (b 2))"},
+		{"`(a `(b ~(c ~(+ 1 1))))", "(a (quasiquote (b (unquote (c 2)))))"},
+		{"`(a `(b ~@(c ~@(list 1 2))))", "(a (quasiquote (b (unquote-splicing (c 1 2)))))"},
+		{"(let ((x 'a)) `~x)", "a"},
+		{"(do (defmacro swap (a b) `(~b ~a)) (swap 1 inc))", "2"},
+
+		// Tail calls
+		{"(do (defn f (n) (if (= n 0) \"done\" (f (- n 1)))) (f 1000000))", "\"done\""},
+		{"(do (defn f (n) (and true (or false (do (let ((m n)) (if (= m 0) m (f (- m 1)))))))) (f 100000))", "0"},
+		{"(do (defn even (n) (if (= n 0) true (odd (- n 1)))) (defn odd (n) (if (= n 0) false (even (- n 1)))) (even 100001))", "false"},
+
+		// Prelude
+		{"(when true 1 2)", "2"},
+		{"(when true (def x 1) (inc x))", "2"},
+		{"(when false 1 2)", "<nil>"},
+
+		{"(unless false 1 2)", "2"},
+		{"(unless true 1 2)", "<nil>"},
+		{"(unless false (def x 1) (inc x))", "2"},
+
+		{"(cond)", "<nil>"},
+		{"(cond (false 1) (true 2 3))", "3"},
+		{"(cond (false 1) ((= 1 2) 2))", "<nil>"},
+		{"(cond (false 1) (else 2 3))", "3"},
+		{"(cond ((= 1 1)) (else 2))", "<nil>"},
+
+		{"(case 1)", "<nil>"},
+		{"(case 2 (1 \"a\") (2 \"b\") (3 \"c\"))", "\"b\""},
+		{"(case (+ 1 2) ((1 2) \"low\") ((3 4) \"high\"))", "\"high\""},
+		{"(case 5 ((1 2) \"low\") ((3 4) \"high\"))", "<nil>"},
+		{"(case 5 ((1 2) \"low\") (else \"other\"))", "\"other\""},
+		{"(case \"b\" (\"a\" 1) (\"b\" 2))", "2"},
+		{"(case 'b ((a) 1) ((b c) 2))", "2"},
+		{"(case '(1 2) (((1 2)) \"pair\"))", "\"pair\""},
+		{"(case nil (nil \"x\") (else \"y\"))", "\"x\""},
+		{"(case 'nil (nil \"x\") (else \"y\"))", "\"y\""},
+		{"(case (first '()) ((1 nil) \"x\") (else \"y\"))", "\"x\""},
+		{"(case true (false \"f\") (true \"t\"))", "\"t\""},
+		{"(case false ((true) \"t\") ((false) \"f\"))", "\"f\""},
+		{"(case nil (false \"f\") (else \"other\"))", "\"other\""},
+		{"(let ((n 0)) (case (do (set! n (inc n)) n) (2 \"twice\") (1 \"once\")))", "\"once\""},
+
+		{"(-> 1)", "1"},
+		{"(-> 1 inc)", "2"},
+		{"(-> 1 inc (- 3) (list 4))", "(-1 4)"},
 
 		// Core functions
 
@@ -53,12 +134,87 @@ func TestEval(t *testing.T) {
 		{"(* 4 2)", "8"},
 		{"(* 4 2 4)", "32"},
 
-		{"(/ 4)", "0"},
+		{"(/ 4)", "1/4"},
 		{"(/ 4 2)", "2"},
-		{"(/ 4 2 4)", "0"},
+		{"(/ 4 2 4)", "1/2"},
 
 		{"(mod 5 2)", "1"},
 
+		{"(+ 1 2.5)", "3.5"},
+		{"(+ 0.5 0.5)", "1.0"},
+		{"(- 1.5)", "-1.5"},
+		{"(- 3 0.5 1)", "1.5"},
+		{"(* 2 1.5)", "3.0"},
+		{"(/ 4.0)", "0.25"},
+		{"(/ 1 4.0)", "0.25"},
+		{"(inc 1.5)", "2.5"},
+		{"(dec 1.5)", "0.5"},
+		{"(mod 5.5 2)", "1.5"},
+
+		{"(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"(- -9223372036854775808 1)", "-9223372036854775809"},
+		{"(- -9223372036854775808)", "9223372036854775808"},
+		{"(* 9223372036854775807 2)", "18446744073709551614"},
+		{"(* -1 -9223372036854775808)", "9223372036854775808"},
+		{"(/ -9223372036854775808 -1)", "9223372036854775808"},
+		{"(inc 9223372036854775807)", "9223372036854775808"},
+		{"(- (+ 9223372036854775807 1) 1)", "9223372036854775807"},
+		{"(int? (- (+ 9223372036854775807 1) 1))", "true"},
+		{"(/ 100000000000000000000 10)", "10000000000000000000"},
+		{"(/ 100000000000000000000 100)", "1000000000000000000"},
+		{"(mod 100000000000000000001 10)", "1"},
+		{"(+ 100000000000000000000 0.5)", "1e+20"},
+		{"(* 1e308 10)", "##Inf"},
+		{"(- (* 1e308 10))", "##-Inf"},
+		{"(- ##Inf ##Inf)", "##NaN"},
+		{"(= ##Inf (* 1e308 10))", "true"},
+		{"(loop ((i 0) (a 0) (b 1)) (if (= i 100) a (recur (inc i) b (+ a b))))", "354224848179261915075"},
+
+		{"3/4", "3/4"},
+		{"-6/8", "-3/4"},
+		{"4/2", "2"},
+		{"(/ 1 3)", "1/3"},
+		{"(/ 5)", "1/5"},
+		{"(/ -1 3)", "-1/3"},
+		{"(/ 100000000000000000000 3)", "100000000000000000000/3"},
+		{"(+ 1/2 1/2)", "1"},
+		{"(int? (+ 1/2 1/2))", "true"},
+		{"(+ 1/2 1/3)", "5/6"},
+		{"(- 1/2 1)", "-1/2"},
+		{"(* 2/3 3/4)", "1/2"},
+		{"(/ 1/2 1/4)", "2"},
+		{"(+ 1/2 0.25)", "0.75"},
+		{"(mod 7/2 1)", "1/2"},
+		{"(mod -7/2 1)", "-1/2"},
+		{"(= 1/2 0.5)", "true"},
+		{"(= 1/2 2/4)", "true"},
+		{"(= 1/3 0.3)", "false"},
+		{"(< 1/3 0.5 1)", "true"},
+		{"(> 1/3 1/4)", "true"},
+		{"(numerator 3/4)", "3"},
+		{"(denominator 3/4)", "4"},
+		{"(numerator -6/8)", "-3"},
+		{"(numerator 5)", "5"},
+		{"(denominator 5)", "1"},
+		{"(ratio? 1/2)", "true"},
+		{"(ratio? 1)", "false"},
+		{"(number? 1/2)", "true"},
+		{"(floor 7/2)", "3"},
+		{"(floor -7/2)", "-4"},
+		{"(ceil 7/2)", "4"},
+		{"(ceil -7/2)", "-3"},
+		{"(round 5/2)", "3"},
+		{"(round -5/2)", "-3"},
+		{"(round 4/3)", "1"},
+		{"(int->float 1/4)", "0.25"},
+
+		{"(int->float 2)", "2.0"},
+		{"(int->float 100000000000000000000)", "1e+20"},
+		{"(floor 1e20)", "100000000000000000000"},
+		{"(floor 1.5)", "1"},
+		{"(ceil 1.5)", "2"},
+		{"(round 2.5)", "3"},
+
 		{"(inc 1)", "2"},
 		{"(dec 1)", "0"},
 
@@ -82,6 +238,8 @@ func TestEval(t *testing.T) {
 		{"(< 1 2)", "true"},
 		{"(< 1 2 3)", "true"},
 		{"(< 1 3 2)", "false"},
+		{"(< 1 1.5 2)", "true"},
+		{"(> 2.5 2 1.5)", "true"},
 
 		{"(= 1)", "true"},
 		{"(= 1 1)", "true"},
@@ -89,13 +247,52 @@ func TestEval(t *testing.T) {
 		{"(= true false)", "false"},
 		{"(= \"abc\" \"abc\")", "true"},
 		{"(= \"abc\" \"xyz\")", "false"},
-		// {"(= '(1 1 true \"abc\") '(1 1 true \"abc\"))", "true"},
-		// {"(= '(1 1 true \"abc\") '(1 1 false \"abc\"))", "false"},
+		{"(= '(1 1 true \"abc\") '(1 1 true \"abc\"))", "true"},
+		{"(= '(1 1 true \"abc\") '(1 1 false \"abc\"))", "false"},
 		{"(= 1 1 1 1)", "true"},
+		{"(= nil nil)", "true"},
+		{"(= 1 1.0)", "true"},
+		{"(= 1.5 1.5)", "true"},
+		{"(= 1 1.5)", "false"},
+		{"(= 100000000000000000000 100000000000000000000)", "true"},
+		{"(= 100000000000000000000 100000000000000000001)", "false"},
+		{"(< 1 100000000000000000000 100000000000000000001)", "true"},
+
+		{"(< ##NaN 1)", "false"},
+		{"(<= ##NaN 1)", "false"},
+		{"(> 1 ##NaN)", "false"},
+		{"(>= 1 ##NaN)", "false"},
+		{"(>= ##NaN ##NaN)", "false"},
+		{"(= ##NaN ##NaN)", "false"},
+		{"(zero? ##NaN)", "false"},
 
 		{"(!= 1 2)", "true"},
 		{"(!= 1 1)", "false"},
 
+		// Lists
+		{"(list)", "()"},
+		{"(list 1 (+ 1 1) nil)", "(1 2 nil)"},
+
+		{"(cons 1 (list))", "(1)"},
+		{"(cons 1 (list 2 3))", "(1 2 3)"},
+		{"(cons 1 nil)", "(1)"},
+
+		{"(first (list 1 2))", "1"},
+		{"(first (list))", "<nil>"},
+
+		{"(rest (list 1 2))", "(2)"},
+		{"(rest (list))", "()"},
+
+		{"(empty? (list))", "true"},
+		{"(empty? nil)", "true"},
+		{"(empty? (list 1))", "false"},
+
+		{"(nth (list 1 2 3) 1)", "2"},
+
+		// Symbols
+		{"(symbol? (gensym))", "true"},
+		{"(= (gensym) (gensym))", "false"},
+
 		// Logic
 		{"(not false)", "true"},
 		{"(not true)", "false"},
@@ -114,6 +311,22 @@ func TestEval(t *testing.T) {
 
 		{"(int? 1)", "true"},
 		{"(int? \"str\")", "false"},
+		{"(int? 1.5)", "false"},
+		{"(int? 100000000000000000000)", "true"},
+
+		{"(float? 1.5)", "true"},
+		{"(float? 1)", "false"},
+
+		{"(number? 1)", "true"},
+		{"(number? 1.5)", "true"},
+		{"(number? \"str\")", "false"},
+
+		{"(zero? 0.0)", "true"},
+		{"(pos? 0.5)", "true"},
+		{"(neg? -0.5)", "true"},
+
+		{`(char? \a)`, "true"},
+		{`(char? "a")`, "false"},
 
 		{"(bool? true)", "true"},
 		{"(bool? 1)", "false"},
@@ -121,11 +334,275 @@ func TestEval(t *testing.T) {
 		{"(string? \"str\")", "true"},
 		{"(string? 1)", "false"},
 
-		// {"(symbol? 'a)", "true"},
+		{"(symbol? 'a)", "true"},
 		{"(symbol? 1)", "false"},
 
-		// {"(list? '(1 2 3))", "true"},
+		{"(list? '(1 2 3))", "true"},
 		{"(list? 1)", "false"},
+
+		// Macros
+		{"(macroexpand-1 (quote (when x 1)))", "(if x (do 1))"},
+		{"(macroexpand-1 (quote (-> 1 inc)))", "(-> (inc 1))"},
+		{"(macroexpand (quote (-> 1 inc)))", "(inc 1)"},
+		{"(macroexpand (quote (+ 1 2)))", "(+ 1 2)"},
+		{"((fn () (defmacro lm (x) x) (macroexpand '(lm 5))))", "5"},
+		{"(let ((x 1)) (defmacro lm (y) (list 'inc y)) (macroexpand-1 '(lm x)))", "(inc x)"},
+
+		// Strings
+		{`"say \"hi\"\n"`, `"say \"hi\"\n"`},
+		{`"""C:\dir"""`, `"C:\\dir"`},
+
+		{"(str)", `""`},
+		{`(str "a" 1 nil \b :c 'd [1 "e"])`, `"a1b:cd[1 \"e\"]"`},
+		{`(substring "hello" 1)`, `"ello"`},
+		{`(substring "hello" 1 3)`, `"el"`},
+		{`(substring "λμν" 1 2)`, `"μ"`},
+		{`(substring "abc" 3)`, `""`},
+		{`(split "a,b,,c" ",")`, `("a" "b" "" "c")`},
+		{`(split "abc" "")`, `("a" "b" "c")`},
+		{`(join '("a" "b" "c"))`, `"abc"`},
+		{`(join ", " [1 "b" \c])`, `"1, b, c"`},
+		{`(join "-" nil)`, `""`},
+		{`(upper "aλ")`, `"AΛ"`},
+		{`(lower "AΛ")`, `"aλ"`},
+		{`(trim "  a b \n")`, `"a b"`},
+		{`(replace "a-b-c" "-" "+")`, `"a+b+c"`},
+		{`(index-of "λab" "b")`, "2"},
+		{`(index-of "abc" "d")`, "<nil>"},
+		{`(starts-with? "hello" "he")`, "true"},
+		{`(starts-with? "hello" "lo")`, "false"},
+		{`(ends-with? "hello" "lo")`, "true"},
+		{`(string-length "Hello, 世界")`, "9"},
+		{`(string->int "42")`, "42"},
+		{`(string->int "-100000000000000000000")`, "-100000000000000000000"},
+		{`(string->int "4x")`, "<nil>"},
+		{"(int->string 42)", `"42"`},
+		{"(int->string 100000000000000000000)", `"100000000000000000000"`},
+
+		// Characters
+		{`(char->int \a)`, "97"},
+		{`(char->int \newline)`, "10"},
+		{"(int->char 955)", `\λ`},
+		{"(int->char 32)", `\space`},
+		{`(= \u{3bb} \λ)`, "true"},
+
+		{`(empty? "")`, "true"},
+		{`(nth "aλb" 1)`, `\λ`},
+		{`(first "λx")`, `\λ`},
+		{`(rest "abc")`, `(\b \c)`},
+		{`(cons \a "bc")`, `(\a \b \c)`},
+
+		// Vectors
+		{"[]", "[]"},
+		{"[1 (+ 1 1) [3]]", "[1 2 [3]]"},
+		{"(do (def x 2) [x 'x])", "[2 x]"},
+		{"(vector 1 2)", "[1 2]"},
+		{"(vector? [1])", "true"},
+		{"(vector? '(1))", "false"},
+		{"(= [1 2] [1 2])", "true"},
+		{"(= [1 2] '(1 2))", "false"},
+		{"(nth [1 2 3] 2)", "3"},
+		{"(count [1 2 3])", "3"},
+		{"(count '(1 2))", "2"},
+		{"(count nil)", "0"},
+		{`(count "aλ")`, "2"},
+		{"(assoc [1 2 3] 1 5)", "[1 5 3]"},
+		{"(assoc [1 2] 2 3 0 0)", "[0 2 3]"},
+		{"(do (def v [1 2]) (assoc v 0 5) v)", "[1 2]"},
+		{"(conj [1 2] 3 4)", "[1 2 3 4]"},
+		{"(let ((v (conj [] 1))) (let ((a (conj v 2)) (b (conj v 3))) (list v a b)))", "([1] [1 2] [1 3])"},
+		{"(let ((v (conj [] 1))) (let ((a (assoc v 1 2)) (b (assoc v 1 3))) (list v a b)))", "([1] [1 2] [1 3])"},
+		{"(count (loop ((i 0) (v [])) (if (= i 100000) v (recur (inc i) (conj v i)))))", "100000"},
+		{"(conj '(1 2) 3 4)", "(4 3 1 2)"},
+		{"(conj nil 1)", "(1)"},
+		{"(do (def v [1]) (conj v 2) (conj v 3))", "[1 3]"},
+		{"(first [1 2])", "1"},
+		{"(rest [1 2])", "(2)"},
+		{"(do (def x 1) `[a ~x ~@(list 2 3)])", "[a 1 2 3]"},
+
+		// Maps
+		{"{}", "{}"},
+		{`{"a" (+ 1 1) 'b [3]}`, `{"a" 2 b [3]}`},
+		{"(hash-map 1 2 3 4)", "{1 2 3 4}"},
+		{"(hash-map 1 2 1 3)", "{1 3}"},
+		{"(map? {})", "true"},
+		{"(map? [])", "false"},
+		{`(get {"a" 1} "a")`, "1"},
+		{`(get {"a" 1} "b")`, "<nil>"},
+		{`(get {"a" 1} "b" 0)`, "0"},
+		{"(get nil 1)", "<nil>"},
+		{"(get [1 2] 1)", "2"},
+		{"(get [1 2] 5 0)", "0"},
+		{"(get {1 'int} 1.0)", "int"},
+		{"(get {1.0 'float} 1)", "float"},
+		{"(get {1/2 'half} 0.5)", "half"},
+		{"(get {0.0 'zero} -0.0)", "zero"},
+		{"(get {100000000000000000000 'big} 1e20)", "big"},
+		{"(get {'(1 2) 'list} '(1 2))", "list"},
+		{"(get {[1 {2 3}] 'nested} [1.0 {2 3}])", "nested"},
+		{"(get {{1 2 3 4} 'map} {3 4 1 2})", "map"},
+		{"(assoc {} 1 2)", "{1 2}"},
+		{"(assoc nil 1 2 3 4)", "{1 2 3 4}"},
+		{"(assoc {1 2} 1.0 3)", "{1 3}"},
+		{"(do (def m {1 2}) (assoc m 3 4) m)", "{1 2}"},
+		{"(dissoc {1 2 3 4} 1)", "{3 4}"},
+		{"(dissoc {1 2 3 4} 1 3 5)", "{}"},
+		{"(do (def m {1 2}) (dissoc m 1) m)", "{1 2}"},
+		{"(keys {1 2 3 4})", "(1 3)"},
+		{"(vals {1 2 3 4})", "(2 4)"},
+		{"(contains? {1 nil} 1)", "true"},
+		{"(contains? {1 2} 2)", "false"},
+		{"(contains? [1 2] 1)", "true"},
+		{"(contains? [1 2] 2)", "false"},
+		{"(merge {1 2 3 4} nil {3 5 6 7})", "{1 2 3 5 6 7}"},
+		{"(merge)", "{}"},
+		{"(= {1 2 3 4} {3 4 1 2})", "true"},
+		{"(= {1 2} {1 3})", "false"},
+		{"(count {1 2 3 4})", "2"},
+		{"(empty? {})", "true"},
+		{"(empty? {1 2})", "false"},
+		{"(first {1 2})", "[1 2]"},
+		{"(do (def x 1) `{a ~x})", "{a 1}"},
+
+		// Sets
+		{"#{}", "#{}"},
+		{"#{1 (+ 1 1) 'a}", "#{1 2 a}"},
+		{"#{1 (- 2 1)}", "#{1}"},
+		{"(hash-set 1 2 1.0)", "#{1 2}"},
+		{"(set? #{})", "true"},
+		{"(set? {})", "false"},
+		{"(contains? #{1 2} 2.0)", "true"},
+		{"(contains? #{1 2} 3)", "false"},
+		{"(contains? #{[1 2]} [1 2])", "true"},
+		{"(conj #{1} 1 2)", "#{1 2}"},
+		{"(disj #{1 2 3} 1 3)", "#{2}"},
+		{"(do (def s #{1}) (conj s 2) (disj s 1) s)", "#{1}"},
+		{"(union #{1 2} #{2 3} nil)", "#{1 2 3}"},
+		{"(union)", "#{}"},
+		{"(intersection #{1 2 3} #{2 3 4} #{3 2})", "#{2 3}"},
+		{"(difference #{1 2 3} #{2} #{3 4})", "#{1}"},
+		{"(subset? #{1 2} #{2 1 3})", "true"},
+		{"(subset? #{1 4} #{1 2 3})", "false"},
+		{"(subset? #{} nil)", "true"},
+		{"(= #{1 2} #{2 1})", "true"},
+		{"(= #{1 2} #{1})", "false"},
+		{"(count #{1 2})", "2"},
+		{"(empty? #{})", "true"},
+		{"(empty? #{1})", "false"},
+		{"(get {#{1 2} 'set} #{2 1})", "set"},
+		{"(do (def x 1) `#{a ~x})", "#{a 1}"},
+
+		// Keywords
+		{":a", ":a"},
+		{"[:a :b/c :a?]", "[:a :b/c :a?]"},
+		{"(= :a :a)", "true"},
+		{"(= :a :b)", "false"},
+		{"(= :a 'a)", "false"},
+		{"(keyword? :a)", "true"},
+		{"(keyword? 'a)", "false"},
+		{`(keyword "a")`, ":a"},
+		{"(keyword 'a)", ":a"},
+		{`(= (keyword "a") :a)`, "true"},
+		{"(:a {:a 1 :b 2})", "1"},
+		{"(:c {:a 1 :b 2})", "<nil>"},
+		{"(:c {:a 1} 3)", "3"},
+		{"(:a nil)", "<nil>"},
+		{"(:a #{:a})", ":a"},
+		{"(get {:a 1} :a)", "1"},
+		{"(do (def m {:status :ok}) (case (:status m) (:ok 1) (:error 2)))", "1"},
+
+		// Sequences
+		{"(append)", "()"},
+		{"(append '(1 2) nil [3] '(4))", "(1 2 3 4)"},
+		{"(reverse '(1 2 3))", "(3 2 1)"},
+		{"(reverse nil)", "()"},
+		{"(map inc '(1 2 3))", "(2 3 4)"},
+		{"(map (fn (x) (* x x)) [1 2 3])", "(1 4 9)"},
+		{"(map + '(1 2 3) '(10 20))", "(11 22)"},
+		{"(map :a [{:a 1} {:a 2}])", "(1 2)"},
+		{"(map inc nil)", "()"},
+		{"(filter pos? '(-1 2 -3 4))", "(2 4)"},
+		{"(filter (fn (x) (if (> x 1) x)) '(1 2 3))", "(2 3)"},
+		{"(filter :a [{:a 1} {:b 2}])", "({:a 1})"},
+		{"(reduce + '(1 2 3 4))", "10"},
+		{"(reduce + 10 '(1 2 3 4))", "20"},
+		{"(reduce + '())", "0"},
+		{"(reduce + 1 '())", "1"},
+		{"(reduce (fn (acc x) (cons x acc)) '() '(1 2 3))", "(3 2 1)"},
+		{"(range 5)", "(0 1 2 3 4)"},
+		{"(range 2 5)", "(2 3 4)"},
+		{"(range 0 10 3)", "(0 3 6 9)"},
+		{"(range 5 0 -2)", "(5 3 1)"},
+		{"(range 0 1 1/4)", "(0 1/4 1/2 3/4)"},
+		{"(range 0 1 0.5)", "(0 0.5)"},
+		{"(range 0)", "()"},
+		{"(range 5 0)", "()"},
+		{"(take 2 '(1 2 3))", "(1 2)"},
+		{"(take 5 '(1 2 3))", "(1 2 3)"},
+		{"(take -1 '(1 2 3))", "()"},
+		{"(drop 2 '(1 2 3))", "(3)"},
+		{"(drop 5 [1 2 3])", "()"},
+		{"(drop 100000000000000000000 '(1 2))", "()"},
+		{"(reduce + (map (fn (x) (* x x)) (filter (fn (x) (= (mod x 2) 0)) (range 10))))", "120"},
+
+		// Functions
+		{"(apply + '(1 2 3))", "6"},
+		{"(apply + 1 2 '(3 4))", "10"},
+		{"(apply list nil)", "()"},
+		{"(apply (fn (a &rest xs) xs) 1 [2 3])", "(2 3)"},
+		{"(apply :a '({:a 1}))", "1"},
+		{"((partial + 1 2) 3 4)", "10"},
+		{"((partial list))", "()"},
+		{"(map (partial * 2) '(1 2 3))", "(2 4 6)"},
+		{"((comp) 1)", "1"},
+		{"((comp inc) 1)", "2"},
+		{"((comp list inc +) 1 2)", "(4)"},
+		{"((comp (fn (x) (* x 2)) inc) 1)", "4"},
+		{"(identity 1)", "1"},
+		{"(map identity [1 2])", "(1 2)"},
+		{"((constantly 1))", "1"},
+		{"((constantly 1) 2 3)", "1"},
+		{"((juxt first count) '(1 2 3))", "[1 3]"},
+		{"((juxt :a :b) {:a 1 :b 2})", "[1 2]"},
+
+		// Regexes
+		{`#"\d+"`, `#"\d+"`},
+		{`(regex? #"a")`, "true"},
+		{`(regex? "a")`, "false"},
+		{`(= #"a+" #"a+")`, "true"},
+		{`(re-pattern "\"\\d\"")`, `#"\"\d\""`},
+		{`(re-find #"\d+" "ab 12 34")`, `"12"`},
+		{`(re-find #"\d+" "ab")`, "<nil>"},
+		{`(re-find #"(\w+)@(\w+)" "mail: me@host")`, `("me@host" "me" "host")`},
+		{`(re-find #"a(x)?b" "ab")`, `("ab" nil)`},
+		{`(re-find #"\"(\w*)\"" "say \"hi\"")`, `("\"hi\"" "hi")`},
+		{`(re-matches #"\d+" "123")`, `"123"`},
+		{`(re-matches #"\d+" "123a")`, "<nil>"},
+		{`(re-matches #"a|ab" "ab")`, `"ab"`},
+		{`(re-matches #"(\d+)-(\d+)" "1-2")`, `("1-2" "1" "2")`},
+		{`(re-seq #"\d" "a1b2c3")`, `("1" "2" "3")`},
+		{`(re-seq #"(\w)=(\d)" "a=1 b=2")`, `(("a=1" "a" "1") ("b=2" "b" "2"))`},
+		{`(re-seq #"x" "abc")`, "()"},
+		{`(re-replace #"\d" "a1b2" "#")`, `"a#b#"`},
+		{`(re-replace #"(\w)=(\d)" "a=1 b=2" "$2=$1")`, `"1=a 2=b"`},
+		{`(re-replace #"\d+" "a1b22" (fn (m) (string-length m)))`, `"a1b2"`},
+		{`(re-replace #"(\w)(\d)" "a1 b2" (fn (m) (nth m 2)))`, `"1 2"`},
+
+		// Formatting
+		{`(format "plain")`, `"plain"`},
+		{`(format "%d|%5d|%-5d|%05d|%+d" 42 42 42 42 42)`, `"42|   42|42   |00042|+42"`},
+		{`(format "%x %X %#x %04x" 255 255 255 10)`, `"ff FF 0xff 000a"`},
+		{`(format "%o %b" 8 5)`, `"10 101"`},
+		{`(format "%d %x" 100000000000000000000 100000000000000000000)`, `"100000000000000000000 56bc75e2d63100000"`},
+		{`(format "%.2f|%8.3f|%f" 3.14159 2 1/4)`, `"3.14|   2.000|0.250000"`},
+		{`(format "%e %g" 1500.0 0.5)`, `"1.500000e+03 0.5"`},
+		{`(format "%s|%5s|%-5s|" "ab" "ab" :a)`, `"ab|   ab|:a   |"`},
+		{`(format "%s %v" "a\"b" "a\"b")`, `"a\"b \"a\\\"b\""`},
+		{`(format "%v %v %s" \a nil nil)`, `"\\a nil nil"`},
+		{`(format "%v" [1 "two" :three])`, `"[1 \"two\" :three]"`},
+		{`(format "%c%c" \λ \a)`, `"λa"`},
+		{`(format "100%%")`, `"100%"`},
+		{`(format "λ=%d" 1)`, `"λ=1"`},
 	}
 
 	for i, c := range cases {
@@ -140,3 +617,267 @@ func TestEval(t *testing.T) {
 		}
 	}
 }
+
+func TestEvalError(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected ErrorKind
+	}{
+		{"(+ 1 \"a\")", EType},
+		{"(< 1 \"a\")", EType},
+		{"(1 2)", EType},
+		{"(inc)", EArity},
+		{"(mod 1 2 3)", EArity},
+		{"((fn (x) x))", EArity},
+		{"((fn (x) x) 1 2)", EArity},
+		{"((fn (x (y 1)) x) 1 2 3)", EArity},
+		{"((fn (x &rest xs) x))", EArity},
+		{"(fn (x &rest) x)", ESyntax},
+		{"(fn (&rest xs ys) x)", ESyntax},
+		{"(fn ((x 1) y) x)", ESyntax},
+		{"(fn (()) x)", ESyntax},
+		{"(/ 1 0)", EDivisionByZero},
+		{"(mod 1 0)", EDivisionByZero},
+		{"(/ 1.5 0)", EDivisionByZero},
+		{"(/ 1 0.0)", EDivisionByZero},
+		{"(int->float 1.5)", EType},
+		{`(char->int "a")`, EType},
+		{"(int->char -1)", ERange},
+		{"(int->char 55296)", ERange},
+		{"(int->char 100000000000000000000)", ERange},
+		{"(nth '(1 2) 2)", ERange},
+		{"(nth [1 2] 100000000000000000000)", ERange},
+		{"(assoc [1 2] 3 0)", ERange},
+		{"(assoc [1 2] 0)", EArity},
+		{"(assoc '(1 2) 0 1)", EType},
+		{"(conj 1 2)", EType},
+		{"(count 1)", EType},
+		{"[1 (+ 1 \"a\")]", EType},
+		{"(loop ((i 0)) [(recur 1)])", ESyntax},
+		{"(loop ((i 0)) {1 (recur 1)})", ESyntax},
+		{"(hash-map 1)", EArity},
+		{"(format)", EArity},
+		{"(format 1)", EType},
+		{`(format "%d")`, EArity},
+		{`(format "%d" 1 2)`, EArity},
+		{`(format "%d" 1.5)`, EType},
+		{`(format "%f" "a")`, EType},
+		{`(format "%c" "a")`, EType},
+		{`(format "%q" 1)`, ESyntax},
+		{`(format "%5" 1)`, ESyntax},
+		{`(format "%5.2.3d" 1)`, ESyntax},
+		{`(format "%5-d" 1)`, ESyntax},
+		{`(printf "%d" "a")`, EType},
+		{`(re-pattern "(")`, ESyntax},
+		{`(re-find "a" "a")`, EType},
+		{`(re-find #"a" 1)`, EType},
+		{`(re-seq #"a")`, EArity},
+		{`(re-replace #"a" "a" 1)`, EType},
+		{`(substring "abc" 4)`, ERange},
+		{`(substring "abc" 2 1)`, ERange},
+		{`(substring "abc" -1)`, ERange},
+		{"(substring 'abc 1)", EType},
+		{`(split "abc" 1)`, EType},
+		{`(join 1 '())`, EType},
+		{"(upper 1)", EType},
+		{`(replace "a" "b")`, EArity},
+		{`(index-of "a" \a)`, EType},
+		{"(string-length nil)", EType},
+		{"(string->int 1)", EType},
+		{`(int->string 1.5)`, EType},
+		{"(apply +)", EArity},
+		{"(apply + 1)", EType},
+		{"(apply 1 '())", EType},
+		{"(partial)", EArity},
+		{"((partial inc 1) 2)", EArity},
+		{"(comp inc 1)", EType},
+		{"(identity)", EArity},
+		{"(juxt)", EArity},
+		{"((juxt inc) \"a\")", EType},
+		{"(map inc)", EArity},
+		{"(map 1 '(1))", EType},
+		{"(map inc '(1 \"a\"))", EType},
+		{"(filter inc 1)", EType},
+		{"(reduce)", EArity},
+		{"(reduce inc '())", EArity},
+		{"(range)", EArity},
+		{"(range \"a\")", EType},
+		{"(range 0 10 0)", ERange},
+		{"(take 1.5 '(1))", EType},
+		{"(drop 1 1)", EType},
+		{"(append '(1) 2)", EType},
+		{"(:a)", EArity},
+		{"(:a {} 1 2)", EArity},
+		{"(:a [1])", EType},
+		{"(keyword 1)", EType},
+		{`(keyword "")`, ESyntax},
+		{"(intersection)", EArity},
+		{"(union #{} [])", EType},
+		{"(subset? #{} {})", EType},
+		{"(loop ((i 0)) #{(recur 1)})", ESyntax},
+		{"(get 1 1)", EType},
+		{"(dissoc [1] 0)", EType},
+		{"(keys [1])", EType},
+		{"(merge {} 1)", EType},
+		{"(contains? 1 1)", EType},
+		{`(nth "ab" -1)`, ERange},
+		{"(nth '(1 2) 1.0)", EType},
+		{"(/ 1/2 0)", EDivisionByZero},
+		{"(numerator 1.5)", EType},
+		{"(denominator \"a\")", EType},
+		{"(floor \"a\")", EType},
+		{"(round (/ 1.0 1e-320 1e-320))", EType},
+		{"(def 1 2)", ESyntax},
+		{"(recur 1)", ESyntax},
+		{"(loop ((i 0)) (recur))", ESyntax},
+		{"(loop ((i 0)) (recur 1 2))", ESyntax},
+		{"(loop ((i 0)) (inc (recur 1)))", ESyntax},
+		{"(loop ((i 0)) (recur 1) i)", ESyntax},
+		{"(loop ((i 0)) (if (recur 1) i))", ESyntax},
+		{"(loop ((i 0)) ((fn () (recur 1))))", ESyntax},
+		{"(loop ((i 0)) `(a ~(recur 1)))", ESyntax},
+		{"(loop ((i 0)) `(a ~@(recur 1)))", ESyntax},
+		{"foo", EUnboundSymbol},
+		{"(prnitln \"x\")", EUnboundSymbol},
+		{"(let ((x 1)) y)", EUnboundSymbol},
+		{"(do (defn f () x) (f))", EUnboundSymbol},
+		{"(nil 1)", EType},
+		{"(first 1)", EType},
+		{"(cons 1 2)", EType},
+		{"(when)", EArity},
+		{"(macroexpand)", EArity},
+		{"(macroexpand-1 1 2)", EArity},
+		{"(set! x 1)", EUnboundSymbol},
+		{"(set! 1 1)", ESyntax},
+		{"~a", ESyntax},
+		{"`~@(list 1)", ESyntax},
+		{"`(a ~@1)", EType},
+		{"(defmacro m (&rest) 1)", ESyntax},
+		{"(if)", ESyntax},
+	}
+
+	for i, c := range cases {
+		_, err := Eval(c.s, NewEnviroment())
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%d: expected = %v, found %v", i, c.expected, err)
+			continue
+		}
+		if c.expected != e.Kind {
+			t.Errorf("%d: expected = %v, found %v", i, c.expected, e.Kind)
+		}
+	}
+}
+
+func TestEvalErrorPos(t *testing.T) {
+	s := "(defn f (x)\n  (+ x \"a\"))\n(f 1)"
+	expected := "test.sp:2:3: type error: expected number, found string"
+
+	_, err := EvalFile("test.sp", s, NewEnviroment())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if expected != err.Error() {
+		t.Errorf("expected = %v, found %v", expected, err)
+	}
+}
+
+func TestEvalErrorTrace(t *testing.T) {
+	s := "(defn f (x) (+ x \"a\"))\n(defn g (x) (inc (f x)))\n(defn h (x) (g x))\n(h 1)"
+	expected := []Frame{
+		{"f", Pos{"test.sp", 2, 18}},
+		// h is not in the trace since it called g in tail position
+		{"g", Pos{"test.sp", 3, 13}},
+	}
+
+	env := NewEnviroment()
+
+	_, err := EvalFile("test.sp", s, env)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected error, found %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, e.Trace) {
+		t.Errorf("expected = %v, found %v", expected, e.Trace)
+	}
+
+	if frames := env.stack.Frames(); len(frames) != 0 {
+		t.Errorf("expected empty stack, found %v", frames)
+	}
+}
+
+func TestEvalSpans(t *testing.T) {
+	env := NewEnviroment()
+	other := NewEnviroment()
+	n := len(other.spans)
+
+	values, err := parseFile("test.sp", "(defn f (x) (+ x 1))\n(f 1)", env.spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if _, err := eval(v, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := values[1].(List)
+	if _, ok := env.spans.PosOf(l); !ok {
+		t.Errorf("expected position of %v", l)
+	}
+	if pos, ok := other.spans.PosOf(l); ok {
+		t.Errorf("expected no position of %v in other environment, found %v", l, pos)
+	}
+	if len(other.spans) != n {
+		t.Errorf("expected %d spans in other environment, found %d", n, len(other.spans))
+	}
+	if child := NewChildEnviroment(env); len(child.spans) != len(env.spans) {
+		t.Errorf("expected child environment to share the spans")
+	}
+}
+
+func TestEvalErrorSuggestion(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected string
+	}{
+		{"(prnitln \"x\")", "1:1: unbound symbol: prnitln, did you mean println?"},
+		{"(let ((count 1)) (inc cuont))", "1:18: unbound symbol: cuont, did you mean count?"},
+		{"(foo 1)", "1:1: unbound symbol: foo"},
+	}
+
+	for i, c := range cases {
+		_, err := Eval(c.s, NewEnviroment())
+		if err == nil {
+			t.Fatalf("%d: expected error", i)
+		}
+
+		if c.expected != err.Error() {
+			t.Errorf("%d: expected = %v, found %v", i, c.expected, err)
+		}
+	}
+}
+
+func TestEvalArityError(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected string
+	}{
+		{"(do (defn f (x) x) (f))", "1:20: arity error: wrong number of arguments to f: expected 1, found 0"},
+		{"(do (defn f (x (y 1)) x) (f))", "1:26: arity error: wrong number of arguments to f: expected 1 to 2, found 0"},
+		{"(do (defn f (x &rest y) x) (f))", "1:28: arity error: wrong number of arguments to f: expected at least 1, found 0"},
+	}
+
+	for i, c := range cases {
+		_, err := Eval(c.s, NewEnviroment())
+		if err == nil {
+			t.Fatalf("%d: expected error", i)
+		}
+
+		if c.expected != err.Error() {
+			t.Errorf("%d: expected = %v, found %v", i, c.expected, err)
+		}
+	}
+}
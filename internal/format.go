@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sprintf formats the arguments according to the directives of the format
+// string, which are similar to Go's fmt ones. Each directive is a percent
+// sign followed by optional flags (-, +, space, 0 or #), a width and a
+// precision after a dot, and ends with one of the following verbs:
+//
+//	%d    an integer in base 10
+//	%x %X an integer in base 16, with lower or upper case letters
+//	%o %b an integer in base 8 or 2
+//	%f %e %g a number as a floating-point value
+//	%s    any value as printed by print
+//	%v    any value in its readable form, as written in a program
+//	%c    a character
+//	%%    a literal percent sign
+func sprintf(format string, args []Value) (string, error) {
+	var b strings.Builder
+	runes := []rune(format)
+	next := 0
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			b.WriteRune(runes[i])
+			continue
+		}
+
+		// The spec contains the flags, width and precision, in that order
+		start := i
+		i++
+		i = skipRunes(runes, i, "-+ 0#")
+		i = skipRunes(runes, i, "0123456789")
+		if i < len(runes) && runes[i] == '.' {
+			i = skipRunes(runes, i+1, "0123456789")
+		}
+		if i == len(runes) {
+			return "", NewError(ESyntax, "incomplete directive %q", string(runes[start:]))
+		}
+		spec, verb := string(runes[start:i]), runes[i]
+
+		if verb == '%' {
+			b.WriteRune('%')
+			continue
+		}
+
+		if next == len(args) {
+			return "", NewError(EArity, "missing argument for directive %q", spec+string(verb))
+		}
+		arg := args[next]
+		next++
+
+		s, err := formatArg(spec, verb, arg)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s)
+	}
+
+	if next != len(args) {
+		return "", NewError(EArity, "too many arguments for format: expected %d, found %d", next, len(args))
+	}
+	return b.String(), nil
+}
+
+// skipRunes returns the index of the first rune from i that is not in chars.
+func skipRunes(runes []rune, i int, chars string) int {
+	for i < len(runes) && strings.ContainsRune(chars, runes[i]) {
+		i++
+	}
+	return i
+}
+
+// formatArg formats a single argument according to the directive.
+func formatArg(spec string, verb rune, arg Value) (string, error) {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b':
+		switch x := arg.(type) {
+		case Int:
+			return fmt.Sprintf(spec+string(verb), int64(x)), nil
+		case BigInt:
+			return fmt.Sprintf(spec+string(verb), x.i), nil
+		default:
+			return "", typeError("int", arg)
+		}
+	case 'f', 'e', 'E', 'g', 'G':
+		if !isNumeric(arg) {
+			return "", typeError("number", arg)
+		}
+		return fmt.Sprintf(spec+string(verb), float64(promote(arg, levelFloat).(Float))), nil
+	case 's':
+		return fmt.Sprintf(spec+"s", display(arg)), nil
+	case 'v':
+		return fmt.Sprintf(spec+"s", toString(arg)), nil
+	case 'c':
+		c, ok := arg.(Char)
+		if !ok {
+			return "", typeError("char", arg)
+		}
+		return fmt.Sprintf(spec+"c", rune(c)), nil
+	default:
+		return "", NewError(ESyntax, "unknown directive %q", spec+string(verb))
+	}
+}
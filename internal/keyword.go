@@ -0,0 +1,75 @@
+package internal
+
+import "sync"
+
+// Keyword is a self-evaluating name, used as a tag or as a map key.
+// Keywords are interned, so two keywords with the same name are the
+// same pointer and can be compared by identity.
+type Keyword struct {
+	name string
+}
+
+// interned contains all the keywords created by name.
+var interned = struct {
+	sync.Mutex
+	m map[string]*Keyword
+}{m: map[string]*Keyword{}}
+
+// NewKeyword returns the keyword with the given name,
+// creating it the first time the name is used.
+func NewKeyword(name string) *Keyword {
+	interned.Lock()
+	defer interned.Unlock()
+
+	k, ok := interned.m[name]
+	if !ok {
+		k = &Keyword{name}
+		interned.m[name] = k
+	}
+	return k
+}
+
+func (k *Keyword) Eval(env *Enviroment) (Value, error) {
+	return k, nil
+}
+
+func (k *Keyword) String() string {
+	return ":" + k.name
+}
+
+func (k *Keyword) Equals(val Value) bool {
+	return k == val
+}
+
+// Name returns the name of the keyword without the leading colon.
+func (k *Keyword) Name() string {
+	return k.name
+}
+
+// Apply looks up the keyword in the map given as the first argument,
+// returning the second argument, or nil, if it is not found.
+func (k *Keyword) Apply(args List) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, arityError(k.String(), "1 or 2", len(args))
+	}
+	var def Value
+	if len(args) == 2 {
+		def = args[1]
+	}
+	switch coll := args[0].(type) {
+	case nil:
+		return def, nil
+	case Map:
+		if val, ok := coll.Get(k); ok {
+			return val, nil
+		}
+		return def, nil
+	case Set:
+		if coll.Contains(k) {
+			return k, nil
+		}
+		return def, nil
+	default:
+		return nil, typeError("map or set", coll)
+	}
+}
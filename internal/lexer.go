@@ -1,11 +1,12 @@
 package internal
 
 import (
-	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // TokenKind indicates the lexical unit of a token.
@@ -15,19 +16,47 @@ const (
 	TUnknown TokenKind = iota
 	TLeftParen
 	TRightParen
+	TLeftBracket
+	TRightBracket
+	TLeftBrace
+	TRightBrace
+	TSetOpen
+	TRegex
+	TQuote
+	TQuasiquote
+	TUnquote
+	TUnquoteSplicing
 	TString
+	TChar
 	TInt
+	TFloat
+	TRatio
 	TBool
 	TSymbol
+	TKeyword
 )
 
 var tokenKinds = [...]string{
-	TLeftParen:  "(",
-	TRightParen: ")",
-	TString:     "STRING",
-	TInt:        "INT",
-	TBool:       "BOOL",
-	TSymbol:     "SYMBOL",
+	TLeftParen:       "(",
+	TRightParen:      ")",
+	TLeftBracket:     "[",
+	TRightBracket:    "]",
+	TLeftBrace:       "{",
+	TRightBrace:      "}",
+	TSetOpen:         "#{",
+	TRegex:           "REGEX",
+	TQuote:           "'",
+	TQuasiquote:      "`",
+	TUnquote:         "~",
+	TUnquoteSplicing: "~@",
+	TString:          "STRING",
+	TChar:            "CHAR",
+	TInt:             "INT",
+	TFloat:           "FLOAT",
+	TRatio:           "RATIO",
+	TBool:            "BOOL",
+	TSymbol:          "SYMBOL",
+	TKeyword:         "KEYWORD",
 }
 
 func (t TokenKind) String() string {
@@ -37,15 +66,35 @@ func (t TokenKind) String() string {
 	return tokenKinds[0]
 }
 
+// Pos represents a position in a source file.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+// IsValid returns whether the position is known.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
 // Token represents a token of the language.
 type Token struct {
 	Kind   TokenKind
 	Lexeme string
+	Pos    Pos
 }
 
 // Tokenize converts a string into a list of tokens.
 func Tokenize(s string) ([]Token, error) {
-	lexer := NewLexer(strings.NewReader(s))
+	lexer := NewLexer("", strings.NewReader(s))
 	tokens := []Token{}
 
 	for {
@@ -67,6 +116,8 @@ func Tokenize(s string) ([]Token, error) {
 type Lexer struct {
 	scanner   io.RuneScanner
 	lookahead *lookahead
+	pos       Pos
+	prev      Pos
 }
 
 type lookahead struct {
@@ -75,8 +126,9 @@ type lookahead struct {
 }
 
 // NewLexer creates a new Lexer initalized with the given io.RuneScanner.
-func NewLexer(scanner io.RuneScanner) *Lexer {
-	return &Lexer{scanner, nil}
+// The filename is only used to report the position of the tokens.
+func NewLexer(filename string, scanner io.RuneScanner) *Lexer {
+	return &Lexer{scanner: scanner, pos: Pos{File: filename, Line: 1, Col: 1}}
 }
 
 // Next consumes and returns the next token. It returns
@@ -92,42 +144,69 @@ func (l *Lexer) Next() (*Token, error) {
 		return nil, err
 	}
 
+	pos := l.pos
+
 	r, err := l.read()
 	if err != nil {
 		return nil, err
 	}
 
+	var token *Token
+
 	switch {
-	case r == ';':
-		if err := l.skipLine(); err != nil {
-			return nil, err
-		}
-		return l.Next()
 	case r == '(':
-		return &Token{TLeftParen, ""}, nil
+		token = &Token{Kind: TLeftParen}
 	case r == ')':
-		return &Token{TRightParen, ""}, nil
+		token = &Token{Kind: TRightParen}
+	case r == '[':
+		token = &Token{Kind: TLeftBracket}
+	case r == ']':
+		token = &Token{Kind: TRightBracket}
+	case r == '{':
+		token = &Token{Kind: TLeftBrace}
+	case r == '}':
+		token = &Token{Kind: TRightBrace}
+	case r == '#':
+		token, err = l.readDispatch()
+	case r == '\'':
+		token = &Token{Kind: TQuote}
+	case r == '`':
+		token = &Token{Kind: TQuasiquote}
+	case r == '~' || r == ',':
+		token, err = l.readUnquote()
 	case r == '"':
-		return l.readString()
+		token, err = l.readString()
+	case r == '\\':
+		token, err = l.readChar()
 	case r == '-':
-		p, err := l.peek()
-		if err != nil {
-			if err == io.EOF {
-				return l.readIdent(r)
-			}
-			return nil, err
+		p, perr := l.peek()
+		if perr != nil && perr != io.EOF {
+			return nil, perr
 		}
-		if unicode.IsDigit(p) {
-			return l.readInt(r)
+		if perr == nil && unicode.IsDigit(p) {
+			token, err = l.readNumber(r)
+		} else {
+			token, err = l.readIdent(r)
 		}
-		return l.readIdent(r)
 	case unicode.IsDigit(r):
-		return l.readInt(r)
+		token, err = l.readNumber(r)
+	case r == ':':
+		token, err = l.readKeyword(r)
 	case isIdent(r):
-		return l.readIdent(r)
+		token, err = l.readIdent(r)
+	default:
+		return nil, syntaxErrorf(pos, "unexpected rune '%c'", r)
 	}
 
-	return nil, fmt.Errorf("unexpected rune '%c'", r)
+	if err != nil {
+		if e, ok := err.(*Error); ok && !e.Pos.IsValid() {
+			e.Pos = pos
+		}
+		return nil, err
+	}
+
+	token.Pos = pos
+	return token, nil
 }
 
 // Peek returns the next token on the source
@@ -163,21 +242,45 @@ func (l *Lexer) peek() (rune, error) {
 // read consumes and returns the next rune on the source.
 func (l *Lexer) read() (rune, error) {
 	r, _, err := l.scanner.ReadRune()
-	return r, err
+	if err != nil {
+		return r, err
+	}
+
+	l.prev = l.pos
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Col = 1
+	} else {
+		l.pos.Col++
+	}
+
+	return r, nil
 }
 
 // unread causes the next call to read to return
 // the same rune as the previous call.
 func (l *Lexer) unread() error {
-	return l.scanner.UnreadRune()
+	if err := l.scanner.UnreadRune(); err != nil {
+		return err
+	}
+	l.pos = l.prev
+	return nil
 }
 
+// skipWhitespace consumes all the whitespace and comments
+// until the beginning of the next token.
 func (l *Lexer) skipWhitespace() error {
 	for {
 		r, err := l.read()
 		if err != nil {
 			return err
 		}
+		if r == ';' {
+			if err := l.skipLine(); err != nil {
+				return err
+			}
+			continue
+		}
 		if !unicode.IsSpace(r) {
 			if err := l.unread(); err != nil {
 				return err
@@ -200,47 +303,386 @@ func (l *Lexer) skipLine() error {
 	}
 }
 
+// readDispatch reads the token that starts with a hash,
+// whose kind depends on the rune that follows it.
+func (l *Lexer) readDispatch() (*Token, error) {
+	r, err := l.read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case err == nil && r == '{':
+		return &Token{Kind: TSetOpen}, nil
+	case err == nil && r == '"':
+		return l.readRegex()
+	case err == nil && r == '#':
+		return l.readSymbolicFloat()
+	default:
+		return nil, syntaxErrorf(Pos{}, "unexpected rune '#'")
+	}
+}
+
+// readSymbolicFloat reads the name of a float that has no numeric literal,
+// which are the infinities and NaN written as ##Inf, ##-Inf and ##NaN.
+func (l *Lexer) readSymbolicFloat() (*Token, error) {
+	r, err := l.read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err == io.EOF || !isIdent(r) {
+		return nil, syntaxErrorf(Pos{}, "missing name after '##'")
+	}
+
+	token, err := l.readIdent(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch token.Lexeme {
+	case "Inf", "-Inf", "NaN":
+		return &Token{Kind: TFloat, Lexeme: token.Lexeme}, nil
+	default:
+		return nil, syntaxErrorf(Pos{}, "unknown symbolic value '##%s'", token.Lexeme)
+	}
+}
+
+// readRegex reads the source of a regex literal verbatim, without processing
+// the escape sequences, so the backslashes are passed to the regex. A double
+// quote preceded by a backslash does not end the literal.
+func (l *Lexer) readRegex() (*Token, error) {
+	buf := []rune{}
+	escaped := false
+
+	for {
+		r, err := l.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, syntaxErrorf(Pos{}, "unterminated regex literal")
+			}
+			return nil, err
+		}
+		if r == '"' && !escaped {
+			return &Token{Kind: TRegex, Lexeme: string(buf)}, nil
+		}
+		escaped = r == '\\' && !escaped
+		buf = append(buf, r)
+	}
+}
+
+func (l *Lexer) readUnquote() (*Token, error) {
+	r, err := l.read()
+	if err != nil {
+		if err == io.EOF {
+			return &Token{Kind: TUnquote}, nil
+		}
+		return nil, err
+	}
+
+	if r == '@' {
+		return &Token{Kind: TUnquoteSplicing}, nil
+	}
+
+	if err := l.unread(); err != nil {
+		return nil, err
+	}
+	return &Token{Kind: TUnquote}, nil
+}
+
+// readString reads a string literal, replacing the escape sequences with
+// the runes they represent. A string that starts with three double quotes
+// is a raw string, which can span multiple lines and has no escapes.
 func (l *Lexer) readString() (*Token, error) {
+	ok, err := l.accept("\"")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		ok, err := l.accept("\"")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return l.readRawString()
+		}
+		return &Token{Kind: TString}, nil
+	}
+
 	buf := []rune{}
 
 	for {
 		r, err := l.read()
 		if err != nil {
 			if err == io.EOF {
-				return nil, errors.New("unterminated string literal")
+				return nil, syntaxErrorf(Pos{}, "unterminated string literal")
 			}
 			return nil, err
 		}
 		if r == '"' {
-			return &Token{TString, string(buf)}, nil
+			return &Token{Kind: TString, Lexeme: string(buf)}, nil
+		}
+		if r == '\\' {
+			if r, err = l.readEscape(); err != nil {
+				return nil, err
+			}
 		}
 		buf = append(buf, r)
 	}
 }
 
-func (l *Lexer) readInt(r rune) (*Token, error) {
-	buf := []rune{r}
+// escapes contains the runes represented by the single
+// rune escape sequences of the string literals.
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+}
+
+// readEscape reads an escape sequence after the backslash and returns the
+// rune it represents. Any Unicode code point can be written in hexadecimal
+// between braces, as in \u{3bb}.
+func (l *Lexer) readEscape() (rune, error) {
+	pos := l.prev
+
+	r, err := l.read()
+	if err != nil {
+		if err == io.EOF {
+			return 0, syntaxErrorf(Pos{}, "unterminated string literal")
+		}
+		return 0, err
+	}
+
+	if e, ok := escapes[r]; ok {
+		return e, nil
+	}
+	if r != 'u' {
+		return 0, syntaxErrorf(pos, "unknown escape sequence '\\%c'", r)
+	}
+
+	ok, err := l.accept("{")
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, syntaxErrorf(pos, "malformed escape sequence '\\u'")
+	}
+
+	return l.readCodePoint(pos)
+}
+
+// readCodePoint reads a Unicode code point in hexadecimal after the opening
+// brace until the closing one. Errors are reported at the given position.
+func (l *Lexer) readCodePoint(pos Pos) (rune, error) {
+	buf := []rune{}
+	for {
+		r, err := l.read()
+		if err != nil {
+			if err == io.EOF {
+				return 0, syntaxErrorf(pos, "unterminated code point '\\u{%s'", string(buf))
+			}
+			return 0, err
+		}
+		if r == '}' {
+			break
+		}
+		buf = append(buf, r)
+	}
+
+	c, err := strconv.ParseUint(string(buf), 16, 32)
+	if err != nil || len(buf) > 6 || !utf8.ValidRune(rune(c)) {
+		return 0, syntaxErrorf(pos, "malformed code point '\\u{%s}'", string(buf))
+	}
+	return rune(c), nil
+}
+
+// readRawString reads the runes verbatim until three double quotes.
+func (l *Lexer) readRawString() (*Token, error) {
+	buf := []rune{}
 
 	for {
 		r, err := l.read()
 		if err != nil {
 			if err == io.EOF {
-				return &Token{TInt, string(buf)}, nil
+				return nil, syntaxErrorf(Pos{}, "unterminated string literal")
 			}
 			return nil, err
 		}
+		buf = append(buf, r)
+		if n := len(buf); n >= 3 && string(buf[n-3:]) == `"""` {
+			return &Token{Kind: TString, Lexeme: string(buf[:n-3])}, nil
+		}
+	}
+}
 
-		if !unicode.IsDigit(r) {
+// readChar reads a character literal after the backslash, which is either
+// a single character, the name of a character or a code point in
+// hexadecimal between braces, as in \u{3bb}. The lexeme contains
+// the character itself.
+func (l *Lexer) readChar() (*Token, error) {
+	r, err := l.read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, syntaxErrorf(Pos{}, "missing character after '\\'")
+		}
+		return nil, err
+	}
+
+	if !unicode.IsLetter(r) {
+		return &Token{Kind: TChar, Lexeme: string(r)}, nil
+	}
+
+	buf := []rune{r}
+	for {
+		r, err := l.read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if !unicode.IsLetter(r) {
 			if err := l.unread(); err != nil {
 				return nil, err
 			}
-			return &Token{TInt, string(buf)}, nil
+			break
+		}
+		buf = append(buf, r)
+	}
+
+	if len(buf) == 1 {
+		if buf[0] == 'u' {
+			ok, err := l.accept("{")
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				r, err := l.readCodePoint(Pos{})
+				if err != nil {
+					return nil, err
+				}
+				return &Token{Kind: TChar, Lexeme: string(r)}, nil
+			}
+		}
+		return &Token{Kind: TChar, Lexeme: string(buf)}, nil
+	}
+
+	if r, ok := charNames[string(buf)]; ok {
+		return &Token{Kind: TChar, Lexeme: string(r)}, nil
+	}
+	return nil, syntaxErrorf(Pos{}, "unknown character name '\\%s'", string(buf))
+}
+
+// readNumber reads an integer, a ratio of two integers separated by a
+// slash or a floating-point number, which has a fractional part, an
+// exponent or both.
+func (l *Lexer) readNumber(r rune) (*Token, error) {
+	kind := TInt
+
+	buf, err := l.readDigits([]rune{r})
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := l.accept("/")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if buf, err = l.readFraction(append(buf, '/')); err != nil {
+			return nil, err
+		}
+		return &Token{Kind: TRatio, Lexeme: string(buf)}, nil
+	}
+
+	ok, err = l.accept(".")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		kind = TFloat
+		if buf, err = l.readFraction(append(buf, '.')); err != nil {
+			return nil, err
+		}
+	}
+
+	ok, err = l.accept("eE")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		kind = TFloat
+		buf = append(buf, 'e')
+
+		ok, err := l.accept("-")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			buf = append(buf, '-')
+		} else if _, err := l.accept("+"); err != nil {
+			return nil, err
+		}
+
+		if buf, err = l.readFraction(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Token{Kind: kind, Lexeme: string(buf)}, nil
+}
+
+// readFraction reads the digits that must follow
+// the decimal point or the exponent of a number.
+func (l *Lexer) readFraction(buf []rune) ([]rune, error) {
+	r, err := l.peek()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err == io.EOF || !unicode.IsDigit(r) {
+		return nil, syntaxErrorf(Pos{}, "malformed number '%s'", string(buf))
+	}
+	return l.readDigits(buf)
+}
+
+// readDigits appends all the following digits to the buffer.
+func (l *Lexer) readDigits(buf []rune) ([]rune, error) {
+	for {
+		r, err := l.read()
+		if err != nil {
+			if err == io.EOF {
+				return buf, nil
+			}
+			return nil, err
+		}
+
+		if !unicode.IsDigit(r) {
+			return buf, l.unread()
 		}
 
 		buf = append(buf, r)
 	}
 }
 
+// accept consumes the next rune if it is any of the given
+// ones, returning whether it was consumed.
+func (l *Lexer) accept(runes string) (bool, error) {
+	r, err := l.read()
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !strings.ContainsRune(runes, r) {
+		return false, l.unread()
+	}
+	return true, nil
+}
+
 // keywords contains all the reserved keywords of the language.
 var keywords = map[string]TokenKind{
 	"true":  TBool,
@@ -273,17 +715,30 @@ func (l *Lexer) readIdent(r rune) (*Token, error) {
 
 	kind, ok := keywords[lexeme]
 	if !ok {
-		return &Token{TSymbol, lexeme}, nil
+		return &Token{Kind: TSymbol, Lexeme: lexeme}, nil
 	}
 
-	return &Token{kind, lexeme}, nil
+	return &Token{Kind: kind, Lexeme: lexeme}, nil
+}
+
+// readKeyword reads a keyword, which is an identifier that starts
+// with a colon. The lexeme contains the name without the colon.
+func (l *Lexer) readKeyword(r rune) (*Token, error) {
+	token, err := l.readIdent(r)
+	if err != nil {
+		return nil, err
+	}
+	if token.Lexeme == ":" {
+		return nil, syntaxErrorf(Pos{}, "missing keyword name after ':'")
+	}
+	return &Token{Kind: TKeyword, Lexeme: token.Lexeme[1:]}, nil
 }
 
 // isIdent returns whether the rune can belong to an identifier.
 func isIdent(r rune) bool {
 	switch r {
 	case '!', '@', '$', '%', '^', '&', '*', '-', '_', '+', '=', '|',
-		'~', ':', '<', '>', '.', '?', '\\', '/', ',':
+		':', '<', '>', '.', '?', '/':
 		return true
 	default:
 		return unicode.IsLetter(r) || unicode.IsDigit(r)
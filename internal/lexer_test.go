@@ -13,11 +13,26 @@ func TestTokenize(t *testing.T) {
 		{"", []TokenKind{}},
 		{" \n\t", []TokenKind{}},
 		{"( )", []TokenKind{TLeftParen, TRightParen}},
+		{"[a]", []TokenKind{TLeftBracket, TSymbol, TRightBracket}},
+		{"{a}", []TokenKind{TLeftBrace, TSymbol, TRightBrace}},
+		{"#{a}", []TokenKind{TSetOpen, TSymbol, TRightBrace}},
+		{`#"\d+" #"a\"b" #"\\"`, []TokenKind{TRegex, TRegex, TRegex}},
 		{`"" "foo"`, []TokenKind{TString, TString}},
+		{`"a\"b" """raw "quoted" string""" "\\"`, []TokenKind{TString, TString, TString}},
 		{"1 12 123", []TokenKind{TInt, TInt, TInt}},
 		{"- -1 -a", []TokenKind{TSymbol, TInt, TSymbol}},
+		{"1.5 -0.25 1e10 1E-10 2.5e+3", []TokenKind{TFloat, TFloat, TFloat, TFloat, TFloat}},
+		{"##Inf ##-Inf ##NaN", []TokenKind{TFloat, TFloat, TFloat}},
+		{"3/4 -1/2 10/5", []TokenKind{TRatio, TRatio, TRatio}},
+		{"\\a \\newline \\λ \\( \\\\ \\u{3bb}", []TokenKind{TChar, TChar, TChar, TChar, TChar, TChar}},
+		{":a :b? a:b", []TokenKind{TKeyword, TKeyword, TSymbol}},
 		{"true false foo", []TokenKind{TBool, TBool, TSymbol}},
-		{"!@$%^&*-_+=|~:<>.?\\/,", []TokenKind{TSymbol}},
+		{"!@$%^&*-_+=|:<>.?/", []TokenKind{TSymbol}},
+		{"'a `a ~a ,a ~@a ,@a", []TokenKind{
+			TQuote, TSymbol, TQuasiquote, TSymbol, TUnquote, TSymbol,
+			TUnquote, TSymbol, TUnquoteSplicing, TSymbol, TUnquoteSplicing, TSymbol,
+		}},
+		{"'(a)", []TokenKind{TQuote, TLeftParen, TSymbol, TRightParen}},
 		{"; foo", []TokenKind{}},
 		{"1 ; foo\n 2", []TokenKind{TInt, TInt}},
 	}
@@ -38,3 +53,21 @@ func TestTokenize(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenizePos(t *testing.T) {
+	tokens, err := Tokenize("(foo\n  \"bar\" ; baz\n -1)")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []Pos{{"", 1, 1}, {"", 1, 2}, {"", 2, 3}, {"", 3, 2}, {"", 3, 4}}
+
+	found := make([]Pos, len(tokens))
+	for i, t := range tokens {
+		found[i] = t.Pos
+	}
+
+	if !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected = %v, found %v", expected, found)
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+// recurValue carries the arguments of a recur form back to its loop.
+type recurValue struct {
+	args List
+}
+
+func (r *recurValue) Eval(env *Enviroment) (Value, error) {
+	return r, nil
+}
+
+func (r *recurValue) String() string {
+	return "<recur>"
+}
+
+func (r *recurValue) Equals(val Value) bool {
+	return false
+}
+
+// evalLoop evaluates the body of a loop form until it
+// returns a value other than the result of a recur form.
+func evalLoop(bindings List, body List, env *Enviroment) (Value, error) {
+	names := make([]Symbol, len(bindings))
+	args := make(List, len(bindings))
+
+	for i, binding := range bindings {
+		b, ok := binding.(List)
+		if !ok || len(b) != 2 {
+			return nil, syntaxError("loop")
+		}
+		name, ok := b[0].(Symbol)
+		if !ok {
+			return nil, syntaxError("loop")
+		}
+		val, err := eval(b[1], env)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name
+		args[i] = val
+	}
+
+	exprs := NewList(NewSymbol("do"))
+	exprs = append(exprs, body...)
+
+	for {
+		// Create a new scope on each iteration so closures
+		// keep the values of the iteration they were created in
+		scope := NewChildEnviroment(env)
+		scope.loop = true
+		for i, name := range names {
+			scope.Define(name, args[i])
+		}
+
+		val, err := evalForm(exprs, scope, true)
+		if err != nil {
+			return nil, err
+		}
+
+		r, ok := val.(*recurValue)
+		if !ok {
+			return val, nil
+		}
+		if len(r.args) != len(names) {
+			return nil, NewError(ESyntax, "recur expects %d arguments, found %d", len(names), len(r.args))
+		}
+		args = r.args
+	}
+}
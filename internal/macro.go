@@ -0,0 +1,71 @@
+package internal
+
+// Macro is a function that receives its arguments unevaluated and returns
+// a new form that is evaluated in place of the macro call.
+type Macro struct {
+	fn *Func
+}
+
+func NewMacro(fn *Func) *Macro {
+	return &Macro{fn}
+}
+
+func (m *Macro) Eval(env *Enviroment) (Value, error) {
+	return m, nil
+}
+
+func (m *Macro) String() string {
+	return "<macro>"
+}
+
+func (m *Macro) Equals(val Value) bool {
+	if v, ok := val.(*Macro); ok {
+		return m == v
+	}
+	return false
+}
+
+// Expand applies the macro to the unevaluated arguments of a call.
+func (m *Macro) Expand(args List) (Value, error) {
+	return m.fn.Apply(args)
+}
+
+// macroexpand1 expands the form once if it is a macro call, returning
+// whether it was expanded.
+func macroexpand1(form Value, env *Enviroment) (Value, bool, error) {
+	l, ok := form.(List)
+	if !ok || l.IsEmpty() {
+		return form, false, nil
+	}
+
+	sym, ok := l[0].(Symbol)
+	if !ok {
+		return form, false, nil
+	}
+
+	val, _ := env.Resolve(sym)
+	m, ok := val.(*Macro)
+	if !ok {
+		return form, false, nil
+	}
+
+	expansion, err := m.Expand(l[1:])
+	if err != nil {
+		return nil, false, err
+	}
+	return expansion, true, nil
+}
+
+// macroexpand expands the form repeatedly until it is no longer a macro call.
+func macroexpand(form Value, env *Enviroment) (Value, error) {
+	for {
+		expansion, ok, err := macroexpand1(form, env)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return form, nil
+		}
+		form = expansion
+	}
+}
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// Map is an association of keys to values, where keys are compared with
+// Equals. Its entries are printed and iterated in the order their keys
+// were first added, and updating it returns a new Map.
+type Map struct {
+	entries []mapEntry
+	// index contains the positions of the entries by the hash of their keys
+	index map[uint64][]int
+}
+
+type mapEntry struct {
+	key Value
+	val Value
+}
+
+func NewMap() Map {
+	return Map{index: map[uint64][]int{}}
+}
+
+// newMapFromPairs creates a new Map from an even number of alternating keys
+// and values, where later values replace the earlier ones of an equal key.
+func newMapFromPairs(pairs []Value) Map {
+	m := NewMap()
+	for i := 0; i < len(pairs); i += 2 {
+		m.set(pairs[i], pairs[i+1])
+	}
+	return m
+}
+
+// pairs returns the keys and values of the map alternated.
+func (m Map) pairs() List {
+	res := make(List, 0, 2*len(m.entries))
+	for _, e := range m.entries {
+		res = append(res, e.key, e.val)
+	}
+	return res
+}
+
+// Eval returns a new Map with its keys and values evaluated.
+func (m Map) Eval(env *Enviroment) (Value, error) {
+	res := NewMap()
+	for _, e := range m.entries {
+		key, err := eval(e.key, env)
+		if err != nil {
+			return nil, err
+		}
+		val, err := eval(e.val, env)
+		if err != nil {
+			return nil, err
+		}
+		res.set(key, val)
+	}
+	return res, nil
+}
+
+func (m Map) String() string {
+	pairs := m.pairs()
+	elems := make([]string, len(pairs))
+	for i, v := range pairs {
+		elems[i] = toString(v)
+	}
+	return "{" + strings.Join(elems, " ") + "}"
+}
+
+func (m Map) Equals(val Value) bool {
+	v, ok := val.(Map)
+	if !ok || m.Len() != v.Len() {
+		return false
+	}
+	for _, e := range m.entries {
+		other, ok := v.Get(e.key)
+		if !ok || !equals(e.val, other) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Map) Len() int {
+	return len(m.entries)
+}
+
+// Get returns the value associated with the key and whether it was found.
+func (m Map) Get(key Value) (Value, bool) {
+	if i, ok := m.find(key); ok {
+		return m.entries[i].val, true
+	}
+	return nil, false
+}
+
+// Keys returns the keys of the map in insertion order.
+func (m Map) Keys() List {
+	keys := make(List, len(m.entries))
+	for i, e := range m.entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// Vals returns the values of the map in the order of their keys.
+func (m Map) Vals() List {
+	vals := make(List, len(m.entries))
+	for i, e := range m.entries {
+		vals[i] = e.val
+	}
+	return vals
+}
+
+// Assoc returns a copy of the map with the key associated to the value.
+func (m Map) Assoc(key, val Value) Map {
+	res := m.copy()
+	res.set(key, val)
+	return res
+}
+
+// Dissoc returns a copy of the map without the key.
+func (m Map) Dissoc(key Value) Map {
+	if _, ok := m.find(key); !ok {
+		return m
+	}
+	res := NewMap()
+	for _, e := range m.entries {
+		if !equals(e.key, key) {
+			res.set(e.key, e.val)
+		}
+	}
+	return res
+}
+
+// find returns the position of the entry of the key and whether it was found.
+func (m Map) find(key Value) (int, bool) {
+	for _, i := range m.index[hash(key)] {
+		if equals(m.entries[i].key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// set associates the key to the value in place, so it must
+// only be used on maps that have not been shared yet.
+func (m *Map) set(key, val Value) {
+	if i, ok := m.find(key); ok {
+		m.entries[i].val = val
+		return
+	}
+	h := hash(key)
+	m.index[h] = append(m.index[h], len(m.entries))
+	m.entries = append(m.entries, mapEntry{key, val})
+}
+
+func (m Map) copy() Map {
+	res := Map{
+		entries: make([]mapEntry, len(m.entries)),
+		index:   make(map[uint64][]int, len(m.index)),
+	}
+	copy(res.entries, m.entries)
+	for h, is := range m.index {
+		res.index[h] = append([]int(nil), is...)
+	}
+	return res
+}
+
+// hash returns a hash of the value consistent with Equals, that is,
+// equal values always have the same hash.
+func hash(val Value) uint64 {
+	h := fnv.New64a()
+
+	if isNumeric(val) {
+		// Numbers of different types can be equal, and all of them
+		// are equal to the float they are promoted to when compared
+		f := float64(promote(val, levelFloat).(Float))
+		if f == 0 {
+			f = 0 // Normalize the negative zero
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+		h.Write(buf[:])
+		return h.Sum64()
+	}
+
+	h.Write([]byte(typeName(val)))
+
+	switch v := val.(type) {
+	case String:
+		h.Write([]byte(v))
+	case Symbol:
+		h.Write([]byte(v))
+	case Char:
+		h.Write([]byte(string(rune(v))))
+	case *Keyword:
+		h.Write([]byte(v.name))
+	case Regex:
+		h.Write([]byte(v.re.String()))
+	case Bool:
+		if v {
+			h.Write([]byte{1})
+		}
+	case List:
+		return hashSeq(h.Sum64(), v)
+	case Vector:
+		return hashSeq(h.Sum64(), v.Elems())
+	case Map:
+		// The hash must not depend on the order of the entries
+		sum := h.Sum64()
+		for _, e := range v.entries {
+			sum += hashSeq(0, NewList(e.key, e.val))
+		}
+		return sum
+	case Set:
+		sum := h.Sum64()
+		for _, e := range v.m.entries {
+			sum += hash(e.key)
+		}
+		return sum
+	}
+
+	return h.Sum64()
+}
+
+// hashSeq combines the hashes of the values in order.
+func hashSeq(seed uint64, vals List) uint64 {
+	sum := seed
+	for _, v := range vals {
+		sum = sum*31 + hash(v)
+	}
+	return sum
+}
@@ -0,0 +1,380 @@
+package internal
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BigInt is an arbitrary-precision integer, used when a result does not fit
+// in an Int. It is always normalized back to an Int when the value fits.
+type BigInt struct {
+	i *big.Int
+}
+
+// NewBigInt creates a new integer from the given big.Int, which should
+// not be modified afterwards. It returns an Int if the value fits in one.
+func NewBigInt(i *big.Int) Value {
+	if i.IsInt64() {
+		return Int(i.Int64())
+	}
+	return BigInt{i}
+}
+
+func (b BigInt) Eval(env *Enviroment) (Value, error) {
+	return b, nil
+}
+
+func (b BigInt) String() string {
+	return b.i.String()
+}
+
+func (b BigInt) Equals(val Value) bool {
+	return numberEquals(b, val)
+}
+
+type Float float64
+
+func NewFloat(f float64) Float {
+	return Float(f)
+}
+
+func (f Float) Eval(env *Enviroment) (Value, error) {
+	return f, nil
+}
+
+func (f Float) String() string {
+	// The infinities and NaN have no numeric literal
+	switch {
+	case math.IsInf(float64(f), 1):
+		return "##Inf"
+	case math.IsInf(float64(f), -1):
+		return "##-Inf"
+	case math.IsNaN(float64(f)):
+		return "##NaN"
+	}
+
+	s := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	if strings.ContainsAny(s, ".e") {
+		return s
+	}
+	// Keep the decimal point so it is read back as a float
+	return s + ".0"
+}
+
+func (f Float) Equals(val Value) bool {
+	return numberEquals(f, val)
+}
+
+// Ratio is an exact rational number, produced by the division of integers.
+// It is always normalized to an integer when the denominator is one.
+type Ratio struct {
+	r *big.Rat
+}
+
+// NewRatio creates a new rational from the given big.Rat, which should not
+// be modified afterwards. It returns an integer if the denominator is one.
+func NewRatio(r *big.Rat) Value {
+	if r.IsInt() {
+		return NewBigInt(new(big.Int).Set(r.Num()))
+	}
+	return Ratio{r}
+}
+
+func (r Ratio) Eval(env *Enviroment) (Value, error) {
+	return r, nil
+}
+
+func (r Ratio) String() string {
+	return r.r.String()
+}
+
+func (r Ratio) Equals(val Value) bool {
+	return numberEquals(r, val)
+}
+
+// Numbers are ordered in a tower where every number of a lower level can
+// be represented in a higher one. Arithmetic between numbers of different
+// levels promotes the lowest one to the level of the highest.
+const (
+	levelInt = iota
+	levelBigInt
+	levelRatio
+	levelFloat
+)
+
+// isNumeric returns whether the value is a number.
+func isNumeric(val Value) bool {
+	_, ok := numberLevel(val)
+	return ok
+}
+
+// numberLevel returns the level of the number in the tower.
+func numberLevel(val Value) (int, bool) {
+	switch val.(type) {
+	case Int:
+		return levelInt, true
+	case BigInt:
+		return levelBigInt, true
+	case Ratio:
+		return levelRatio, true
+	case Float:
+		return levelFloat, true
+	default:
+		return 0, false
+	}
+}
+
+// toNumber returns an error if the value is not a number.
+func toNumber(val Value) (Value, error) {
+	if !isNumeric(val) {
+		return nil, typeError("number", val)
+	}
+	return val, nil
+}
+
+// promote converts the number to the given level of the tower.
+func promote(val Value, level int) Value {
+	switch level {
+	case levelBigInt:
+		switch v := val.(type) {
+		case Int:
+			return BigInt{big.NewInt(int64(v))}
+		}
+	case levelRatio:
+		switch v := val.(type) {
+		case Int:
+			return Ratio{new(big.Rat).SetInt64(int64(v))}
+		case BigInt:
+			return Ratio{new(big.Rat).SetInt(v.i)}
+		}
+	case levelFloat:
+		switch v := val.(type) {
+		case Int:
+			return Float(v)
+		case BigInt:
+			f, _ := new(big.Float).SetInt(v.i).Float64()
+			return Float(f)
+		case Ratio:
+			f, _ := v.r.Float64()
+			return Float(f)
+		}
+	}
+	return val
+}
+
+// coerce converts both numbers to the highest level between them.
+func coerce(x, y Value) (Value, Value, error) {
+	xl, ok := numberLevel(x)
+	if !ok {
+		return nil, nil, typeError("number", x)
+	}
+
+	yl, ok := numberLevel(y)
+	if !ok {
+		return nil, nil, typeError("number", y)
+	}
+
+	if xl < yl {
+		return promote(x, yl), y, nil
+	}
+	return x, promote(y, xl), nil
+}
+
+func addNumbers(x, y Value) (Value, error) {
+	x, y, err := coerce(x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := x.(type) {
+	case Int:
+		y := y.(Int)
+		r := x + y
+		if (x^r)&(y^r) < 0 {
+			return addNumbers(promote(x, levelBigInt), y)
+		}
+		return r, nil
+	case BigInt:
+		return NewBigInt(new(big.Int).Add(x.i, y.(BigInt).i)), nil
+	case Ratio:
+		return NewRatio(new(big.Rat).Add(x.r, y.(Ratio).r)), nil
+	default:
+		return x.(Float) + y.(Float), nil
+	}
+}
+
+func subNumbers(x, y Value) (Value, error) {
+	x, y, err := coerce(x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := x.(type) {
+	case Int:
+		y := y.(Int)
+		r := x - y
+		if (x^y)&(x^r) < 0 {
+			return subNumbers(promote(x, levelBigInt), y)
+		}
+		return r, nil
+	case BigInt:
+		return NewBigInt(new(big.Int).Sub(x.i, y.(BigInt).i)), nil
+	case Ratio:
+		return NewRatio(new(big.Rat).Sub(x.r, y.(Ratio).r)), nil
+	default:
+		return x.(Float) - y.(Float), nil
+	}
+}
+
+func mulNumbers(x, y Value) (Value, error) {
+	x, y, err := coerce(x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := x.(type) {
+	case Int:
+		y := y.(Int)
+		if x == 0 || y == 0 {
+			return Int(0), nil
+		}
+		r := x * y
+		if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return mulNumbers(promote(x, levelBigInt), y)
+		}
+		return r, nil
+	case BigInt:
+		return NewBigInt(new(big.Int).Mul(x.i, y.(BigInt).i)), nil
+	case Ratio:
+		return NewRatio(new(big.Rat).Mul(x.r, y.(Ratio).r)), nil
+	default:
+		return x.(Float) * y.(Float), nil
+	}
+}
+
+func divNumbers(x, y Value) (Value, error) {
+	x, y, err := coerce(x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	if isZeroNumber(y) {
+		return nil, NewError(EDivisionByZero, "")
+	}
+
+	// The division of integers is exact, producing a Ratio
+	// when the dividend is not a multiple of the divisor
+	switch x := x.(type) {
+	case Int:
+		y := y.(Int)
+		if x%y != 0 || (x == math.MinInt64 && y == -1) {
+			return divNumbers(promote(x, levelBigInt), y)
+		}
+		return x / y, nil
+	case BigInt:
+		return NewRatio(new(big.Rat).SetFrac(x.i, y.(BigInt).i)), nil
+	case Ratio:
+		return NewRatio(new(big.Rat).Quo(x.r, y.(Ratio).r)), nil
+	default:
+		return x.(Float) / y.(Float), nil
+	}
+}
+
+func modNumbers(x, y Value) (Value, error) {
+	x, y, err := coerce(x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	if isZeroNumber(y) {
+		return nil, NewError(EDivisionByZero, "")
+	}
+
+	switch x := x.(type) {
+	case Int:
+		return x % y.(Int), nil
+	case BigInt:
+		return NewBigInt(new(big.Int).Rem(x.i, y.(BigInt).i)), nil
+	case Ratio:
+		// Truncate the quotient like the integer remainder does
+		y := y.(Ratio)
+		q := new(big.Rat).Quo(x.r, y.r)
+		t := new(big.Int).Quo(q.Num(), q.Denom())
+		r := new(big.Rat).Mul(y.r, new(big.Rat).SetInt(t))
+		return NewRatio(r.Sub(x.r, r)), nil
+	default:
+		return Float(math.Mod(float64(x.(Float)), float64(y.(Float)))), nil
+	}
+}
+
+// compareNumbers returns -1, 0 or +1 depending on whether x is less, equal
+// or greater than y, and false if they are unordered because one is NaN.
+func compareNumbers(x, y Value) (int, bool, error) {
+	x, y, err := coerce(x, y)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var lt, gt bool
+
+	switch x := x.(type) {
+	case Int:
+		lt, gt = x < y.(Int), x > y.(Int)
+	case BigInt:
+		c := x.i.Cmp(y.(BigInt).i)
+		lt, gt = c < 0, c > 0
+	case Ratio:
+		c := x.r.Cmp(y.(Ratio).r)
+		lt, gt = c < 0, c > 0
+	default:
+		if math.IsNaN(float64(x.(Float))) || math.IsNaN(float64(y.(Float))) {
+			return 0, false, nil
+		}
+		lt, gt = x.(Float) < y.(Float), x.(Float) > y.(Float)
+	}
+
+	switch {
+	case lt:
+		return -1, true, nil
+	case gt:
+		return 1, true, nil
+	default:
+		return 0, true, nil
+	}
+}
+
+// numberEquals returns whether both values are numerically equal numbers.
+func numberEquals(x, y Value) bool {
+	c, ok, err := compareNumbers(x, y)
+	return err == nil && ok && c == 0
+}
+
+// isZeroNumber returns whether the number is zero.
+func isZeroNumber(val Value) bool {
+	return numberEquals(val, Int(0))
+}
+
+// floorRat returns the greatest integer less than or equal to r.
+func floorRat(r *big.Rat) *big.Int {
+	// The Euclidean division rounds down for positive denominators
+	q, _ := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	return q
+}
+
+// ceilRat returns the least integer greater than or equal to r.
+func ceilRat(r *big.Rat) *big.Int {
+	q := floorRat(new(big.Rat).Neg(r))
+	return q.Neg(q)
+}
+
+// roundRat returns the nearest integer to r, rounding half away from zero.
+func roundRat(r *big.Rat) *big.Int {
+	half := big.NewRat(1, 2)
+	if r.Sign() >= 0 {
+		return floorRat(new(big.Rat).Add(r, half))
+	}
+	q := floorRat(new(big.Rat).Add(new(big.Rat).Neg(r), half))
+	return q.Neg(q)
+}
@@ -1,32 +1,74 @@
 package internal
 
 import (
-	"errors"
-	"fmt"
 	"io"
+	"math/big"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Parse converts a string into a list of values.
 func Parse(s string) ([]Value, error) {
-	parser := NewParser(NewLexer(strings.NewReader(s)))
+	return ParseFile("", s)
+}
+
+// ParseFile converts the contents of the named source file into a list of values.
+func ParseFile(filename string, s string) ([]Value, error) {
+	return parseFile(filename, s, Spans{})
+}
+
+// parseFile converts the contents of the named source file into a list of
+// values, recording the position of the parsed lists in the given spans.
+func parseFile(filename string, s string, spans Spans) ([]Value, error) {
+	parser := NewParser(NewLexer(filename, strings.NewReader(s)))
+	parser.spans = spans
 	return parser.Parse()
 }
 
+// Spans records the position in the source of parsed lists. Since lists
+// are slices, they are identified by the address of their first element.
+type Spans map[*Value]Pos
+
+// set records the position in the source of the list.
+func (s Spans) set(l List, pos Pos) {
+	if !l.IsEmpty() {
+		s[&l[0]] = pos
+	}
+}
+
+// PosOf returns the position in the source of the list, if it was parsed.
+func (s Spans) PosOf(l List) (Pos, bool) {
+	if l.IsEmpty() {
+		return Pos{}, false
+	}
+	pos, ok := s[&l[0]]
+	return pos, ok
+}
+
 // Parser implements a recursive descent parser.
 //
 // It accepts the following grammar:
 //
-// root  = value { value }
-// value = list | INT | BOOL | STRING | SYMBOL
-// list  = '(' { value } ')'
+// root   = value { value }
+// value  = list | vector | map | set | quote | INT | RATIO | FLOAT | BOOL | STRING | CHAR | REGEX | SYMBOL | KEYWORD
+// list   = '(' { value } ')'
+// vector = '[' { value } ']'
+// map    = '{' { value value } '}'
+// set    = '#{' { value } '}'
+// quote  = ( "'" | '`' | '~' | '~@' ) value
 type Parser struct {
 	lexer *Lexer
+	spans Spans
 }
 
 func NewParser(lexer *Lexer) *Parser {
-	return &Parser{lexer}
+	return &Parser{lexer: lexer, spans: Spans{}}
+}
+
+// Spans returns the positions in the source of the lists parsed so far.
+func (p *Parser) Spans() Spans {
+	return p.spans
 }
 
 func (p *Parser) Parse() ([]Value, error) {
@@ -55,40 +97,125 @@ func (p *Parser) parseValue() (Value, error) {
 	switch token.Kind {
 	case TLeftParen:
 		return p.parseList()
+	case TLeftBracket:
+		return p.parseVector()
+	case TLeftBrace:
+		return p.parseMap()
+	case TSetOpen:
+		return p.parseSet()
+	case TQuote, TQuasiquote, TUnquote, TUnquoteSplicing:
+		return p.parseQuote()
 	case TInt:
 		return p.parseInt()
+	case TRatio:
+		return p.parseRatio()
+	case TFloat:
+		return p.parseFloat()
 	case TBool:
 		return p.parseBool()
 	case TString:
 		return p.parseString()
+	case TChar:
+		return p.parseChar()
+	case TRegex:
+		return p.parseRegex()
 	case TSymbol:
 		return p.parseSymbol()
+	case TKeyword:
+		return p.parseKeyword()
 	default:
-		return nil, fmt.Errorf("unexpected token '%s'", token.Kind)
+		return nil, syntaxErrorf(token.Pos, "unexpected token '%s'", token.Kind)
 	}
 }
 
 func (p *Parser) parseList() (Value, error) {
-	if err := p.advance(); err != nil {
+	start, err := p.lexer.Peek()
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := p.parseElems(TLeftParen, TRightParen, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	list := NewList(elems...)
+	p.spans.set(list, start.Pos)
+	return list, nil
+}
+
+func (p *Parser) parseVector() (Value, error) {
+	elems, err := p.parseElems(TLeftBracket, TRightBracket, "vector")
+	if err != nil {
+		return nil, err
+	}
+	return NewVector(elems...), nil
+}
+
+func (p *Parser) parseMap() (Value, error) {
+	start, err := p.lexer.Peek()
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := p.parseElems(TLeftBrace, TRightBrace, "map")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(elems)%2 != 0 {
+		return nil, syntaxErrorf(start.Pos, "map literal must contain an even number of forms")
+	}
+
+	m := newMapFromPairs(elems)
+	if m.Len() != len(elems)/2 {
+		return nil, syntaxErrorf(start.Pos, "duplicate key in map literal")
+	}
+	return m, nil
+}
+
+func (p *Parser) parseSet() (Value, error) {
+	start, err := p.lexer.Peek()
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := p.parseElems(TSetOpen, TRightBrace, "set")
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewSet(elems...)
+	if s.Len() != len(elems) {
+		return nil, syntaxErrorf(start.Pos, "duplicate element in set literal")
+	}
+	return s, nil
+}
+
+// parseElems parses the values between the given delimiters,
+// using the name of the collection to report it unterminated.
+func (p *Parser) parseElems(left, right TokenKind, name string) ([]Value, error) {
+	start, err := p.match(left)
+	if err != nil {
 		return nil, err
 	}
 
-	list := NewList()
+	elems := []Value{}
 
 	for {
 		token, err := p.lexer.Peek()
 		if err != nil {
 			if err == io.EOF {
-				return nil, errors.New("unterminated list")
+				return nil, syntaxErrorf(start.Pos, "unterminated %s", name)
 			}
 			return nil, err
 		}
 
-		if token.Kind == TRightParen {
+		if token.Kind == right {
 			if err := p.advance(); err != nil {
 				return nil, err
 			}
-			break
+			return elems, nil
 		}
 
 		value, err := p.parseValue()
@@ -96,9 +223,34 @@ func (p *Parser) parseList() (Value, error) {
 			return nil, err
 		}
 
-		list = append(list, value)
+		elems = append(elems, value)
+	}
+}
+
+// quoteForms contains the forms that the reader shorthands expand to.
+var quoteForms = map[TokenKind]Symbol{
+	TQuote:           "quote",
+	TQuasiquote:      "quasiquote",
+	TUnquote:         "unquote",
+	TUnquoteSplicing: "unquote-splicing",
+}
+
+func (p *Parser) parseQuote() (Value, error) {
+	token, err := p.lexer.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		if err == io.EOF {
+			return nil, syntaxErrorf(token.Pos, "missing value after '%s'", token.Kind)
+		}
+		return nil, err
 	}
 
+	list := NewList(quoteForms[token.Kind], value)
+	p.spans.set(list, token.Pos)
 	return list, nil
 }
 
@@ -108,12 +260,42 @@ func (p *Parser) parseInt() (Value, error) {
 		return nil, err
 	}
 
-	val, err := strconv.ParseInt(token.Lexeme, 10, 64)
+	// Integers that do not fit in an Int are read as a BigInt
+	val, ok := new(big.Int).SetString(token.Lexeme, 10)
+	if !ok {
+		return nil, syntaxErrorf(token.Pos, "invalid int literal '%s'", token.Lexeme)
+	}
+
+	return NewBigInt(val), nil
+}
+
+func (p *Parser) parseRatio() (Value, error) {
+	token, err := p.match(TRatio)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewInt(val), nil
+	// Ratios are normalized, so 4/2 is read as the integer 2
+	val, ok := new(big.Rat).SetString(token.Lexeme)
+	if !ok {
+		return nil, syntaxErrorf(token.Pos, "invalid ratio literal '%s'", token.Lexeme)
+	}
+
+	return NewRatio(val), nil
+}
+
+func (p *Parser) parseFloat() (Value, error) {
+	token, err := p.match(TFloat)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := strconv.ParseFloat(token.Lexeme, 64)
+	if err != nil {
+		return nil, syntaxErrorf(token.Pos, "invalid float literal '%s'", token.Lexeme)
+	}
+
+	return NewFloat(val), nil
 }
 
 func (p *Parser) parseBool() (Value, error) {
@@ -124,7 +306,7 @@ func (p *Parser) parseBool() (Value, error) {
 
 	val, err := strconv.ParseBool(token.Lexeme)
 	if err != nil {
-		return nil, err
+		return nil, syntaxErrorf(token.Pos, "invalid bool literal '%s'", token.Lexeme)
 	}
 
 	return NewBool(val), nil
@@ -138,6 +320,29 @@ func (p *Parser) parseString() (Value, error) {
 	return NewString(token.Lexeme), nil
 }
 
+func (p *Parser) parseChar() (Value, error) {
+	token, err := p.match(TChar)
+	if err != nil {
+		return nil, err
+	}
+
+	r, _ := utf8.DecodeRuneInString(token.Lexeme)
+	return NewChar(r), nil
+}
+
+func (p *Parser) parseRegex() (Value, error) {
+	token, err := p.match(TRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := NewRegex(token.Lexeme)
+	if err != nil {
+		return nil, syntaxErrorf(token.Pos, "invalid regex: %v", err)
+	}
+	return re, nil
+}
+
 func (p *Parser) parseSymbol() (Value, error) {
 	token, err := p.match(TSymbol)
 	if err != nil {
@@ -146,6 +351,14 @@ func (p *Parser) parseSymbol() (Value, error) {
 	return NewSymbol(token.Lexeme), nil
 }
 
+func (p *Parser) parseKeyword() (Value, error) {
+	token, err := p.match(TKeyword)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyword(token.Lexeme), nil
+}
+
 func (p *Parser) advance() error {
 	_, err := p.lexer.Next()
 	return err
@@ -157,7 +370,7 @@ func (p *Parser) match(kind TokenKind) (*Token, error) {
 		return nil, err
 	}
 	if token.Kind != kind {
-		return nil, fmt.Errorf("unexpected token '%s'", token.Kind)
+		return nil, syntaxErrorf(token.Pos, "unexpected token '%s'", token.Kind)
 	}
 	return token, nil
 }
@@ -8,7 +8,19 @@ import (
 func TestParse(t *testing.T) {
 	cases := []string{
 		`1 true false "foo"`,
+		"1.5 -0.25 1.0 1e+10",
+		"##Inf ##-Inf ##NaN",
+		"9223372036854775807 9223372036854775808 -100000000000000000000",
+		"3/4 -1/2 100000000000000000000/3",
+		`"a\"b\\c\nd\te\r\0" "λ \u{7f}"`,
+		`\a \λ \newline \space \tab \return \( \\ \" \u{7f}`,
+		"[] [1 [2 3] (foo)]",
+		`{} {1 2 "a" {b (c)}}`,
+		"#{} #{1 #{2} (foo)}",
+		"{:a 1 :b-c :d}",
+		`#"" #"\d+\.\d*" #"say \"(\w+)\""`,
 		"(foo 1 2 3)",
+		"(quote a) (quasiquote (a (unquote b) (unquote-splicing c)))",
 	}
 
 	for i, c := range cases {
@@ -28,3 +40,83 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseString(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected string
+	}{
+		{`""`, ""},
+		{`"foo"`, "foo"},
+		{`"a\"b\""`, `a"b"`},
+		{`"a\\b"`, `a\b`},
+		{`"a\nb\tc"`, "a\nb\tc"},
+		{`"\u{3bb}\u{1F600}"`, "λ😀"},
+		{"\"a\nb\"", "a\nb"},
+		{`""""""`, ""},
+		{`"""a "b" \n"""`, `a "b" \n`},
+		{"\"\"\"a\n  b\"\"\"", "a\n  b"},
+	}
+
+	for i, c := range cases {
+		values, err := Parse(c.s)
+		if err != nil {
+			t.Fatalf("%d: err: %v", i, err)
+		}
+
+		if len(values) != 1 || values[0] != NewString(c.expected) {
+			t.Errorf("%d: expected = %q, found %v", i, c.expected, values)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected string
+	}{
+		{"(foo\n  (bar)", "test.sp:1:1: syntax error: unterminated list"},
+		{"(foo\n  \"bar)", "test.sp:2:3: syntax error: unterminated string literal"},
+		{`(foo "a\qb")`, "test.sp:1:8: syntax error: unknown escape sequence '\\q'"},
+		{`(foo "\u3bb")`, "test.sp:1:7: syntax error: malformed escape sequence '\\u'"},
+		{`(foo "\u{110000}")`, "test.sp:1:7: syntax error: malformed code point '\\u{110000}'"},
+		{`(foo "\u{zz}")`, "test.sp:1:7: syntax error: malformed code point '\\u{zz}'"},
+		{"(foo \"\"\"bar\"\")", "test.sp:1:6: syntax error: unterminated string literal"},
+		{`(foo \foo)`, "test.sp:1:6: syntax error: unknown character name '\\foo'"},
+		{`(foo \u{zz})`, "test.sp:1:6: syntax error: malformed code point '\\u{zz}'"},
+		{"(foo \\", "test.sp:1:6: syntax error: missing character after '\\'"},
+		{"(foo\n  [bar)", "test.sp:2:7: syntax error: unexpected token ')'"},
+		{"[foo\n  (bar)", "test.sp:1:1: syntax error: unterminated vector"},
+		{"(foo])", "test.sp:1:5: syntax error: unexpected token ']'"},
+		{"(foo {1 2 3})", "test.sp:1:6: syntax error: map literal must contain an even number of forms"},
+		{"(foo {1 2 1.0 3})", "test.sp:1:6: syntax error: duplicate key in map literal"},
+		{"{foo\n  (bar)", "test.sp:1:1: syntax error: unterminated map"},
+		{"(foo})", "test.sp:1:5: syntax error: unexpected token '}'"},
+		{"(foo #{1 1.0})", "test.sp:1:6: syntax error: duplicate element in set literal"},
+		{"(foo #{1", "test.sp:1:6: syntax error: unterminated set"},
+		{"(foo #(1))", "test.sp:1:6: syntax error: unexpected rune '#'"},
+		{"(foo : a)", "test.sp:1:6: syntax error: missing keyword name after ':'"},
+		{`(foo #"a(")`, "test.sp:1:6: syntax error: invalid regex: error parsing regexp: missing closing ): `a(`"},
+		{`(foo #"a\")`, "test.sp:1:6: syntax error: unterminated regex literal"},
+		{"(foo))", "test.sp:1:6: syntax error: unexpected token ')'"},
+		{"\n  #", "test.sp:2:3: syntax error: unexpected rune '#'"},
+		{"(foo 1.)", "test.sp:1:6: syntax error: malformed number '1.'"},
+		{"(foo 1/)", "test.sp:1:6: syntax error: malformed number '1/'"},
+		{"(foo 1/0)", "test.sp:1:6: syntax error: invalid ratio literal '1/0'"},
+		{"(foo 1e)", "test.sp:1:6: syntax error: malformed number '1e'"},
+		{"(foo ##inf)", "test.sp:1:6: syntax error: unknown symbolic value '##inf'"},
+		{"(foo ##)", "test.sp:1:6: syntax error: missing name after '##'"},
+		{"(foo '", "test.sp:1:6: syntax error: missing value after '''"},
+	}
+
+	for i, c := range cases {
+		_, err := ParseFile("test.sp", c.s)
+		if err == nil {
+			t.Fatalf("%d: expected error", i)
+		}
+
+		if c.expected != err.Error() {
+			t.Errorf("%d: expected = %v, found %v", i, c.expected, err)
+		}
+	}
+}
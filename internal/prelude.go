@@ -0,0 +1,68 @@
+package internal
+
+// prelude contains the definitions written in Slip itself
+// that are predefined in the global environment.
+//
+// Since the source is a Go raw string, quasiquote is written
+// in its long form instead of using the backquote shorthand.
+const prelude = `
+;; when evaluates the body if the test is true.
+(defmacro when (test &rest body)
+  (quasiquote (if ~test (do ~@body))))
+
+;; unless evaluates the body if the test is not true.
+(defmacro unless (test &rest body)
+  (quasiquote (if ~test nil (do ~@body))))
+
+;; cond evaluates the body of the first clause whose test is true. The test
+;; of the last clause can be else, which always matches.
+(defmacro cond (&rest clauses)
+  (if (empty? clauses)
+      nil
+      (let ((test (first (first clauses)))
+            (body (rest (first clauses))))
+        (if (= test 'else)
+            (quasiquote (do ~@body))
+            (quasiquote (if ~test
+                            (do ~@body)
+                            (cond ~@(rest clauses))))))))
+
+;; case evaluates the body of the first clause whose datum, or any of its
+;; list of datums, is equal to the key. The datums are not evaluated, except
+;; for nil which stands for the nil value, and the last clause can be else,
+;; which always matches.
+(defmacro case (key &rest clauses)
+  (cond ((not (symbol? key))
+         ;; Bind the key so it is evaluated only once
+         (let ((sym (gensym)))
+           (quasiquote (let ((~sym ~key))
+                         (case ~sym ~@clauses)))))
+        ((empty? clauses) nil)
+        (else
+         (let ((datums (first (first clauses)))
+               (body (rest (first clauses))))
+           (cond ((= datums 'else)
+                  (quasiquote (do ~@body)))
+                 ((not (list? datums))
+                  (quasiquote (case ~key ((~datums) ~@body) ~@(rest clauses))))
+                 ((empty? datums)
+                  (quasiquote (case ~key ~@(rest clauses))))
+                 (else
+                  (quasiquote (if (= ~key ~(let ((datum (first datums)))
+                                             (if (= datum 'nil)
+                                                 nil
+                                                 (list 'quote datum))))
+                                  (do ~@body)
+                                  (case ~key (~(rest datums) ~@body) ~@(rest clauses))))))))))
+
+;; -> threads the value through the forms, inserting it as
+;; the first argument of each one.
+(defmacro -> (x &rest forms)
+  (if (empty? forms)
+      x
+      (let ((form (first forms)))
+        (quasiquote (-> ~(if (list? form)
+                             (quasiquote (~(first form) ~x ~@(rest form)))
+                             (list form x))
+                        ~@(rest forms))))))
+`
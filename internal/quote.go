@@ -0,0 +1,105 @@
+package internal
+
+// quasiquote returns the template with the unquoted expressions replaced by
+// their values. The depth is the number of enclosing quasiquote forms, as only
+// the expressions unquoted as many times as they are quasiquoted are evaluated.
+func quasiquote(template Value, depth int, env *Enviroment) (Value, error) {
+	if v, ok := template.(Vector); ok {
+		res, err := quasiquoteElems(v.Elems(), depth, env)
+		if err != nil {
+			return nil, err
+		}
+		return NewVector(res...), nil
+	}
+
+	if m, ok := template.(Map); ok {
+		res, err := quasiquoteElems(m.pairs(), depth, env)
+		if err != nil {
+			return nil, err
+		}
+		if len(res)%2 != 0 {
+			return nil, NewError(ESyntax, "map literal must contain an even number of forms")
+		}
+		return newMapFromPairs(res), nil
+	}
+
+	if s, ok := template.(Set); ok {
+		res, err := quasiquoteElems(s.Elems(), depth, env)
+		if err != nil {
+			return nil, err
+		}
+		return NewSet(res...), nil
+	}
+
+	l, ok := template.(List)
+	if !ok || l.IsEmpty() {
+		return template, nil
+	}
+
+	switch l[0] {
+	case Symbol("unquote"):
+		if len(l) != 2 {
+			return nil, syntaxError("unquote")
+		}
+		if depth == 1 {
+			return eval(l[1], env)
+		}
+		return quasiquoteNested(l, depth-1, env)
+
+	case Symbol("unquote-splicing"):
+		if len(l) != 2 {
+			return nil, syntaxError("unquote-splicing")
+		}
+		if depth == 1 {
+			return nil, NewError(ESyntax, "unquote-splicing outside of list")
+		}
+		return quasiquoteNested(l, depth-1, env)
+
+	case Symbol("quasiquote"):
+		if len(l) != 2 {
+			return nil, syntaxError("quasiquote")
+		}
+		return quasiquoteNested(l, depth+1, env)
+	}
+
+	return quasiquoteElems(l, depth, env)
+}
+
+// quasiquoteElems returns the elements of the template expanded,
+// splicing the values of the unquote-splicing forms.
+func quasiquoteElems(l List, depth int, env *Enviroment) (List, error) {
+	res := make(List, 0, len(l))
+
+	for _, elem := range l {
+		if e, ok := elem.(List); ok && depth == 1 && len(e) == 2 && e[0] == Symbol("unquote-splicing") {
+			val, err := eval(e[1], env)
+			if err != nil {
+				return nil, err
+			}
+			spliced, err := toList(val)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, spliced...)
+			continue
+		}
+
+		val, err := quasiquote(elem, depth, env)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+
+	return res, nil
+}
+
+// quasiquoteNested returns the form with its argument
+// expanded as a template at the given depth.
+func quasiquoteNested(form List, depth int, env *Enviroment) (Value, error) {
+	val, err := quasiquote(form[1], depth, env)
+	if err != nil {
+		return nil, err
+	}
+	return NewList(form[0], val), nil
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Regex is a compiled regular expression with the syntax of Go's regexp.
+type Regex struct {
+	re *regexp.Regexp
+	// anchored only matches the whole text
+	anchored *regexp.Regexp
+}
+
+// NewRegex compiles the source of a regular expression.
+func NewRegex(src string) (Regex, error) {
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return Regex{}, err
+	}
+	anchored, err := regexp.Compile(`^(?:` + src + `)\z`)
+	if err != nil {
+		return Regex{}, err
+	}
+	return Regex{re, anchored}, nil
+}
+
+func (r Regex) Eval(env *Enviroment) (Value, error) {
+	return r, nil
+}
+
+// String returns the regex as a literal that can be read back,
+// escaping the double quotes that are not escaped already.
+func (r Regex) String() string {
+	var b strings.Builder
+	b.WriteString(`#"`)
+	escaped := false
+	for _, c := range r.re.String() {
+		if c == '"' && !escaped {
+			b.WriteRune('\\')
+		}
+		escaped = c == '\\' && !escaped
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (r Regex) Equals(val Value) bool {
+	if v, ok := val.(Regex); ok {
+		return r.re.String() == v.re.String()
+	}
+	return false
+}
+
+// match returns the text of a match found at the given indexes, as
+// returned by FindStringSubmatchIndex. It is a String when the regex
+// has no groups, otherwise a List with the whole match followed by
+// the groups, which are nil if they did not participate.
+func (r Regex) match(s string, loc []int) Value {
+	if r.re.NumSubexp() == 0 {
+		return NewString(s[loc[0]:loc[1]])
+	}
+	groups := make(List, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = NewString(s[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	return groups
+}
@@ -26,14 +26,22 @@ func REPL() error {
 			return fmt.Errorf("failed to read line: %v", err)
 		}
 
-		values, err := Parse(line)
+		values, err := parseFile("<repl>", line, env.spans)
 		if err != nil {
 			fmt.Println(err)
 			continue
 		}
 
 		for _, value := range values {
-			res := value.Eval(env)
+			res, err := eval(value, env)
+			if err != nil {
+				if e, ok := err.(*Error); ok {
+					fmt.Println(e.Traceback())
+				} else {
+					fmt.Println(err)
+				}
+				break
+			}
 			if res == nil {
 				fmt.Println("nil")
 			} else {
@@ -0,0 +1,83 @@
+package internal
+
+import "strings"
+
+// Set is a collection of distinct values, compared with Equals. Adding
+// a value that it already contains leaves the set as it was.
+type Set struct {
+	// m associates each element to itself
+	m Map
+}
+
+func NewSet(vals ...Value) Set {
+	s := Set{NewMap()}
+	for _, v := range vals {
+		s.m.set(v, v)
+	}
+	return s
+}
+
+// Eval returns a new Set with its elements evaluated.
+func (s Set) Eval(env *Enviroment) (Value, error) {
+	res := NewSet()
+	for _, e := range s.m.entries {
+		val, err := eval(e.key, env)
+		if err != nil {
+			return nil, err
+		}
+		res.m.set(val, val)
+	}
+	return res, nil
+}
+
+func (s Set) String() string {
+	elems := make([]string, len(s.m.entries))
+	for i, e := range s.m.entries {
+		elems[i] = toString(e.key)
+	}
+	return "#{" + strings.Join(elems, " ") + "}"
+}
+
+func (s Set) Equals(val Value) bool {
+	v, ok := val.(Set)
+	return ok && s.Len() == v.Len() && s.IsSubset(v)
+}
+
+func (s Set) Len() int {
+	return s.m.Len()
+}
+
+// Contains returns whether the value is an element of the set.
+func (s Set) Contains(val Value) bool {
+	_, ok := s.m.find(val)
+	return ok
+}
+
+// Elems returns the elements of the set in insertion order.
+func (s Set) Elems() List {
+	return s.m.Keys()
+}
+
+// Conj returns a copy of the set with the values added.
+func (s Set) Conj(vals ...Value) Set {
+	res := Set{s.m.copy()}
+	for _, v := range vals {
+		res.m.set(v, v)
+	}
+	return res
+}
+
+// Disj returns a copy of the set without the value.
+func (s Set) Disj(val Value) Set {
+	return Set{s.m.Dissoc(val)}
+}
+
+// IsSubset returns whether all the elements of the set are in the other.
+func (s Set) IsSubset(other Set) bool {
+	for _, e := range s.m.entries {
+		if !other.Contains(e.key) {
+			return false
+		}
+	}
+	return true
+}
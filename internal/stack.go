@@ -0,0 +1,44 @@
+package internal
+
+// Frame represents the application of a function.
+type Frame struct {
+	// Name is the name of the applied function.
+	Name string
+	// Pos is the position in the source of the call site.
+	Pos Pos
+}
+
+func (f Frame) String() string {
+	if !f.Pos.IsValid() {
+		return "in " + f.Name
+	}
+	return "in " + f.Name + " called at " + f.Pos.String()
+}
+
+// CallStack records the functions being applied during the evaluation,
+// from the outermost to the innermost.
+type CallStack struct {
+	frames []Frame
+}
+
+func (s *CallStack) push(frame Frame) {
+	s.frames = append(s.frames, frame)
+}
+
+func (s *CallStack) pop() {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// truncate pops all the frames above the given depth.
+func (s *CallStack) truncate(depth int) {
+	s.frames = s.frames[:depth]
+}
+
+// Frames returns a copy of the current frames, from the innermost to the outermost.
+func (s *CallStack) Frames() []Frame {
+	frames := make([]Frame, len(s.frames))
+	for i, frame := range s.frames {
+		frames[len(frames)-1-i] = frame
+	}
+	return frames
+}
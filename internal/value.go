@@ -3,10 +3,11 @@ package internal
 import (
 	"fmt"
 	"strings"
+	"unicode"
 )
 
 type Value interface {
-	Eval(env *Enviroment) Value
+	Eval(env *Enviroment) (Value, error)
 	String() string
 	Equals(Value) bool
 }
@@ -17,8 +18,8 @@ func NewInt(i int64) Int {
 	return Int(i)
 }
 
-func (i Int) Eval(env *Enviroment) Value {
-	return i
+func (i Int) Eval(env *Enviroment) (Value, error) {
+	return i, nil
 }
 
 func (i Int) String() string {
@@ -30,10 +31,7 @@ func (i Int) IsNil() bool {
 }
 
 func (i Int) Equals(val Value) bool {
-	if v, ok := val.(Int); ok {
-		return i == v
-	}
-	return false
+	return numberEquals(i, val)
 }
 
 type Bool bool
@@ -50,8 +48,8 @@ func NewBool(b bool) Bool {
 	return False
 }
 
-func (b Bool) Eval(env *Enviroment) Value {
-	return b
+func (b Bool) Eval(env *Enviroment) (Value, error) {
+	return b, nil
 }
 
 func (b Bool) String() string {
@@ -71,12 +69,39 @@ func NewString(s string) String {
 	return String(s)
 }
 
-func (s String) Eval(env *Enviroment) Value {
-	return s
+func (s String) Eval(env *Enviroment) (Value, error) {
+	return s, nil
 }
 
+// String returns the string as a literal that can be read back, escaping
+// the double quotes, backslashes and non-printable runes.
 func (s String) String() string {
-	return fmt.Sprintf("%q", string(s))
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range string(s) {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0:
+			b.WriteString(`\0`)
+		default:
+			if unicode.IsPrint(r) {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(&b, "\\u{%x}", r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
 }
 
 func (s String) Equals(val Value) bool {
@@ -92,8 +117,12 @@ func NewSymbol(s string) Symbol {
 	return Symbol(s)
 }
 
-func (s Symbol) Eval(env *Enviroment) Value {
-	return env.Resolve(s)
+func (s Symbol) Eval(env *Enviroment) (Value, error) {
+	val, ok := env.Resolve(s)
+	if !ok {
+		return nil, unboundError(s, env)
+	}
+	return val, nil
 }
 
 func (s Symbol) String() string {
@@ -113,104 +142,303 @@ func NewList(vals ...Value) List {
 	return List(vals)
 }
 
-func (l List) Eval(env *Enviroment) Value {
-	if l.IsEmpty() {
-		return nil
-	}
+func (l List) Eval(env *Enviroment) (Value, error) {
+	return eval(l, env)
+}
 
+// step evaluates the list as a single form, leaving the expression
+// in tail position, if any, to be evaluated by the caller.
+func (l List) step(env *Enviroment) (step, error) {
 	if sym, ok := l[0].(Symbol); ok {
 		switch sym {
 		case "and":
-			var last Value
-			for _, expr := range l[1:] {
-				last = expr.Eval(env)
-				if last == nil || last.Equals(False) {
-					return last
+			if len(l) == 1 {
+				return done(True), nil
+			}
+			for _, expr := range l[1 : len(l)-1] {
+				val, err := eval(expr, env)
+				if err != nil {
+					return step{}, err
+				}
+				if val == nil || val.Equals(False) {
+					return done(val), nil
 				}
 			}
-			return last
+			return tail(l[len(l)-1], env), nil
 
 		case "def":
-			env.Define(l[1].(Symbol), l[2].Eval(env))
-			return nil
+			if len(l) != 3 {
+				return step{}, syntaxError(sym)
+			}
+			name, ok := l[1].(Symbol)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			val, err := eval(l[2], env)
+			if err != nil {
+				return step{}, err
+			}
+			env.Define(name, val)
+			return done(nil), nil
 
 		case "defn":
-			sym := l[1].(Symbol)
-			params := l[2].(List)
-			exprs := l[3:]
-			fn := NewFunc(params, exprs, env)
-			env.Define(sym, fn)
-			return nil
+			if len(l) < 3 {
+				return step{}, syntaxError(sym)
+			}
+			name, ok := l[1].(Symbol)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			params, ok := l[2].(List)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			fn, err := NewFunc(params, l[3:], env)
+			if err != nil {
+				return step{}, err
+			}
+			fn.name = string(name)
+			env.Define(name, fn)
+			return done(nil), nil
 
-		case "do":
-			var last Value
-			for _, expr := range l[1:] {
-				last = expr.Eval(env)
+		case "defmacro":
+			if len(l) < 3 {
+				return step{}, syntaxError(sym)
+			}
+			name, ok := l[1].(Symbol)
+			if !ok {
+				return step{}, syntaxError(sym)
 			}
-			return last
+			params, ok := l[2].(List)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			fn, err := NewFunc(params, l[3:], env)
+			if err != nil {
+				return step{}, err
+			}
+			fn.name = string(name)
+			env.Define(name, NewMacro(fn))
+			return done(nil), nil
+
+		case "do":
+			return evalBody(l[1:], env)
 
 		case "fn":
-			params := l[1].(List)
-			exprs := l[2:]
-			return NewFunc(params, exprs, env)
+			if len(l) < 2 {
+				return step{}, syntaxError(sym)
+			}
+			params, ok := l[1].(List)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			fn, err := NewFunc(params, l[2:], env)
+			if err != nil {
+				return step{}, err
+			}
+			return done(fn), nil
 
 		case "if":
-			test := l[1].Eval(env)
+			if len(l) < 3 || len(l) > 4 {
+				return step{}, syntaxError(sym)
+			}
+
+			test, err := eval(l[1], env)
+			if err != nil {
+				return step{}, err
+			}
 
 			if b, ok := test.(Bool); ok && bool(b) {
-				return l[2].Eval(env)
-			} else if len(l) >= 4 {
-				return l[3].Eval(env)
+				return tail(l[2], env), nil
+			} else if len(l) == 4 {
+				return tail(l[3], env), nil
 			}
 
-			return nil
+			return done(nil), nil
 
 		case "let":
-			bindings := l[1].(List)
-			exprs := NewList(l[2].(List))
+			if len(l) < 2 {
+				return step{}, syntaxError(sym)
+			}
+			bindings, ok := l[1].(List)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
 
-			params := NewList()
-			args := NewList()
+			scope := NewChildEnviroment(env)
 
 			for _, binding := range bindings {
-				b := binding.(List)
-				params = append(params, b[0])
-				args = append(args, b[1])
+				b, ok := binding.(List)
+				if !ok || len(b) != 2 {
+					return step{}, syntaxError(sym)
+				}
+				name, ok := b[0].(Symbol)
+				if !ok {
+					return step{}, syntaxError(sym)
+				}
+				val, err := eval(b[1], env)
+				if err != nil {
+					return step{}, err
+				}
+				scope.Define(name, val)
 			}
 
-			return NewFunc(params, exprs, env).Apply(args)
+			return evalBody(l[2:], scope)
+
+		case "loop":
+			if len(l) < 2 {
+				return step{}, syntaxError(sym)
+			}
+			bindings, ok := l[1].(List)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			val, err := evalLoop(bindings, l[2:], env)
+			if err != nil {
+				return step{}, err
+			}
+			return done(val), nil
+
+		case "macroexpand", "macroexpand-1":
+			// The macros are resolved on the environment of the call,
+			// so they can also be the ones defined in a local scope
+			if err := checkArity(string(sym), l[1:], 1); err != nil {
+				return step{}, err
+			}
+			form, err := eval(l[1], env)
+			if err != nil {
+				return step{}, err
+			}
+			if sym == "macroexpand-1" {
+				expansion, _, err := macroexpand1(form, env)
+				return done(expansion), err
+			}
+			expansion, err := macroexpand(form, env)
+			return done(expansion), err
 
 		case "or":
-			var last Value
-			for _, expr := range l[1:] {
-				last = expr.Eval(env)
-				if last != nil && !last.Equals(False) {
-					return last
+			if len(l) == 1 {
+				return done(nil), nil
+			}
+			for _, expr := range l[1 : len(l)-1] {
+				val, err := eval(expr, env)
+				if err != nil {
+					return step{}, err
+				}
+				if val != nil && !val.Equals(False) {
+					return done(val), nil
 				}
 			}
-			return last
+			return tail(l[len(l)-1], env), nil
 
 		case "quote":
-			return l[1]
+			if len(l) != 2 {
+				return step{}, syntaxError(sym)
+			}
+			return done(l[1]), nil
+
+		case "quasiquote":
+			if len(l) != 2 {
+				return step{}, syntaxError(sym)
+			}
+			val, err := quasiquote(l[1], 1, env)
+			if err != nil {
+				return step{}, err
+			}
+			return done(val), nil
+
+		case "set!":
+			if len(l) != 3 {
+				return step{}, syntaxError(sym)
+			}
+			name, ok := l[1].(Symbol)
+			if !ok {
+				return step{}, syntaxError(sym)
+			}
+			val, err := eval(l[2], env)
+			if err != nil {
+				return step{}, err
+			}
+			if !env.Set(name, val) {
+				return step{}, unboundError(name, env)
+			}
+			return done(nil), nil
+
+		case "unquote", "unquote-splicing":
+			return step{}, NewError(ESyntax, "%s outside of quasiquote", sym)
+
+		case "recur":
+			// The tail position is checked by eval and the number of
+			// arguments by the loop that receives them
+			if !env.inLoop() {
+				return step{}, NewError(ESyntax, "recur outside of loop")
+			}
+			args := make(List, 0, len(l)-1)
+			for _, expr := range l[1:] {
+				arg, err := eval(expr, env)
+				if err != nil {
+					return step{}, err
+				}
+				args = append(args, arg)
+			}
+			return done(&recurValue{args}), nil
 		}
 	}
 
-	args := NewList()
+	head, err := eval(l[0], env)
+	if err != nil {
+		return step{}, err
+	}
+
+	if m, ok := head.(*Macro); ok {
+		expansion, err := m.Expand(l[1:])
+		if err != nil {
+			return step{}, err
+		}
+		return tail(expansion, env), nil
+	}
+
+	args := make(List, 0, len(l)-1)
 	for _, expr := range l[1:] {
-		args = append(args, expr.Eval(env))
+		arg, err := eval(expr, env)
+		if err != nil {
+			return step{}, err
+		}
+		args = append(args, arg)
 	}
 
-	switch fn := l[0].Eval(env).(type) {
+	switch fn := head.(type) {
 	case *Func:
-		return fn.Apply(args)
-	case NativeFunc:
-		return fn.Apply(args)
+		scope, err := fn.bind(args)
+		if err != nil {
+			return step{}, err
+		}
+		next := tail(fn.exprs, scope)
+		next.fn = fn
+		return next, nil
+	case Callable:
+		val, err := fn.Apply(args)
+		if err != nil {
+			return step{}, err
+		}
+		return done(val), nil
 	default:
-		if fn == nil {
-			return nil
+		return step{}, NewError(EType, "%s is not a function", typeName(fn))
+	}
+}
+
+// evalBody evaluates all the expressions but the last one,
+// which is left in tail position.
+func evalBody(exprs List, env *Enviroment) (step, error) {
+	if exprs.IsEmpty() {
+		return done(nil), nil
+	}
+	for _, expr := range exprs[:len(exprs)-1] {
+		if _, err := eval(expr, env); err != nil {
+			return step{}, err
 		}
-		panic(fmt.Errorf("unexpected type '%T'", fn))
 	}
+	return tail(exprs[len(exprs)-1], env), nil
 }
 
 func (l List) String() string {
@@ -220,7 +448,7 @@ func (l List) String() string {
 
 	elems := make([]string, len(l))
 	for i, v := range l {
-		elems[i] = v.String()
+		elems[i] = toString(v)
 	}
 
 	return "(" + strings.Join(elems, " ") + ")"
@@ -233,7 +461,7 @@ func (l List) Equals(val Value) bool {
 		}
 
 		for i, e := range l {
-			if !e.Equals(v[i]) {
+			if !equals(e, v[i]) {
 				return false
 			}
 		}
@@ -250,17 +478,23 @@ func (l List) Len() int {
 	return len(l)
 }
 
-type NativeFunc func(...Value) Value
+// Callable is a value that can be applied to a list of arguments.
+type Callable interface {
+	Value
+	Apply(args List) (Value, error)
+}
+
+type NativeFunc func(...Value) (Value, error)
 
-func NewNativeFunc(fn func(...Value) Value) NativeFunc {
+func NewNativeFunc(fn func(...Value) (Value, error)) NativeFunc {
 	return NativeFunc(fn)
 }
 
-func (nf NativeFunc) Eval(env *Enviroment) Value {
-	return nf
+func (nf NativeFunc) Eval(env *Enviroment) (Value, error) {
+	return nf, nil
 }
 
-func (nf NativeFunc) Apply(args List) Value {
+func (nf NativeFunc) Apply(args List) (Value, error) {
 	return nf(args...)
 }
 
@@ -273,27 +507,141 @@ func (nf NativeFunc) Equals(val Value) bool {
 }
 
 type Func struct {
-	params List
-	exprs  List
-	env    *Enviroment
-}
-
-func NewFunc(params List, exprs List, env *Enviroment) *Func {
+	name     string
+	params   []Symbol
+	optional []optionalParam
+	rest     Symbol
+	exprs    List
+	env      *Enviroment
+}
+
+// optionalParam is a parameter that takes the value of
+// the init expression when its argument is not given.
+type optionalParam struct {
+	sym  Symbol
+	init Value
+}
+
+// NewFunc creates a new function closed over the given environment.
+//
+// The parameters are a list of required symbols, followed by optional
+// parameters in the form (symbol init) and finally, an optional &rest
+// symbol that is bound to a list with all the remaining arguments.
+func NewFunc(params List, exprs List, env *Enviroment) (*Func, error) {
 	list := NewList(NewSymbol("do"))
 	list = append(list, exprs...)
-	return &Func{params: params, exprs: list, env: env}
+	fn := &Func{exprs: list, env: env}
+
+	for i := 0; i < len(params); i++ {
+		switch param := params[i].(type) {
+		case Symbol:
+			if param == "&rest" {
+				if i != len(params)-2 {
+					return nil, NewError(ESyntax, "&rest must be followed by a single parameter")
+				}
+				rest, ok := params[i+1].(Symbol)
+				if !ok {
+					return nil, NewError(ESyntax, "malformed parameter %s", params[i+1])
+				}
+				fn.rest = rest
+				return fn, nil
+			}
+			if len(fn.optional) > 0 {
+				return nil, NewError(ESyntax, "required parameter %s after optional parameters", param)
+			}
+			fn.params = append(fn.params, param)
+		case List:
+			if len(param) != 2 {
+				return nil, NewError(ESyntax, "malformed parameter %s", param)
+			}
+			sym, ok := param[0].(Symbol)
+			if !ok {
+				return nil, NewError(ESyntax, "malformed parameter %s", param)
+			}
+			fn.optional = append(fn.optional, optionalParam{sym, param[1]})
+		default:
+			return nil, NewError(ESyntax, "malformed parameter %s", param)
+		}
+	}
+
+	return fn, nil
+}
+
+func (f *Func) Eval(env *Enviroment) (Value, error) {
+	return f, nil
 }
 
-func (f *Func) Eval(env *Enviroment) Value {
-	return f
+func (f *Func) Apply(args List) (Value, error) {
+	env, err := f.bind(args)
+	if err != nil {
+		return nil, err
+	}
+
+	f.env.stack.push(Frame{Name: f.Name()})
+	defer f.env.stack.pop()
+
+	return eval(f.exprs, env)
 }
 
-func (f *Func) Apply(args List) Value {
+// bind returns a new scope for the body of the function
+// with the parameters bound to the given arguments.
+func (f *Func) bind(args List) (*Enviroment, error) {
+	required, optional := len(f.params), len(f.optional)
+	if len(args) < required || (f.rest == "" && len(args) > required+optional) {
+		return nil, arityError(f.Name(), f.arity(), len(args))
+	}
+
 	env := NewChildEnviroment(f.env)
 	for i, param := range f.params {
-		env.Define(param.(Symbol), args[i])
+		env.Define(param, args[i])
 	}
-	return f.exprs.Eval(env)
+
+	for i, param := range f.optional {
+		if required+i < len(args) {
+			env.Define(param.sym, args[required+i])
+			continue
+		}
+
+		// Evaluate the init expression in the function scope,
+		// so it can refer to the previous parameters
+		val, err := eval(param.init, env)
+		if err != nil {
+			return nil, err
+		}
+		env.Define(param.sym, val)
+	}
+
+	if f.rest != "" {
+		rest := NewList()
+		if len(args) > required+optional {
+			rest = append(rest, args[required+optional:]...)
+		}
+		env.Define(f.rest, rest)
+	}
+
+	return env, nil
+}
+
+// arity returns a description of the number of arguments the function accepts.
+func (f *Func) arity() string {
+	required, optional := len(f.params), len(f.optional)
+	switch {
+	case f.rest != "":
+		return fmt.Sprintf("at least %d", required)
+	case optional > 0:
+		return fmt.Sprintf("%d to %d", required, required+optional)
+	default:
+		return fmt.Sprint(required)
+	}
+}
+
+// Name returns the name the function was defined with or a placeholder
+// when the function is anonymous.
+func (f *Func) Name() string {
+	if f.name == "" {
+		return "<anonymous>"
+	}
+	return f.name
 }
 
 func (f *Func) String() string {
@@ -306,3 +654,16 @@ func (f *Func) Equals(val Value) bool {
 	}
 	return false
 }
+
+// toString returns the representation of the value, considering nil values.
+func toString(val Value) string {
+	if val == nil {
+		return "nil"
+	}
+	return val.String()
+}
+
+// syntaxError returns an error indicating that the special form is malformed.
+func syntaxError(form Symbol) *Error {
+	return NewError(ESyntax, "malformed %s", form)
+}
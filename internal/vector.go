@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// Vector is an indexed sequence of values. Unlike a List, it is not
+// a call when evaluated but a new Vector with its elements evaluated.
+//
+// Adding values at the end of a vector reuses its backing array when there
+// is room left and no other vector has already added values after it, so
+// building a vector one value at a time takes amortized constant time.
+type Vector struct {
+	elems []Value
+	// tail is the length of the backing array in use, shared
+	// by all the vectors that have the same backing array
+	tail *int64
+}
+
+func NewVector(vals ...Value) Vector {
+	n := int64(len(vals))
+	return Vector{elems: vals, tail: &n}
+}
+
+func (v Vector) Eval(env *Enviroment) (Value, error) {
+	res := make([]Value, len(v.elems))
+	for i, expr := range v.elems {
+		val, err := eval(expr, env)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = val
+	}
+	return NewVector(res...), nil
+}
+
+func (v Vector) String() string {
+	elems := make([]string, len(v.elems))
+	for i, e := range v.elems {
+		elems[i] = toString(e)
+	}
+	return "[" + strings.Join(elems, " ") + "]"
+}
+
+func (v Vector) Equals(val Value) bool {
+	if w, ok := val.(Vector); ok {
+		return v.Elems().Equals(w.Elems())
+	}
+	return false
+}
+
+func (v Vector) Len() int {
+	return len(v.elems)
+}
+
+// Nth returns the value at the index, which must be in range.
+func (v Vector) Nth(i int) Value {
+	return v.elems[i]
+}
+
+// Elems returns the values of the vector. The list must not be modified.
+func (v Vector) Elems() List {
+	// Limit the capacity so appending to the list never writes to the vector
+	return List(v.elems[:len(v.elems):len(v.elems)])
+}
+
+// Assoc returns a vector with the value at the given index replaced, which
+// copies all the values. The index can also be the length to add the value
+// at the end as Conj does.
+func (v Vector) Assoc(i int, val Value) Vector {
+	if i == len(v.elems) {
+		return v.Conj(val)
+	}
+	res := make([]Value, len(v.elems))
+	copy(res, v.elems)
+	res[i] = val
+	return NewVector(res...)
+}
+
+// Conj returns a vector with the values added at the end.
+func (v Vector) Conj(vals ...Value) Vector {
+	n := len(v.elems) + len(vals)
+
+	// Claim the free space at the end of the backing array, which is only
+	// possible if no other vector has been built on top of this one
+	if v.tail != nil && n <= cap(v.elems) &&
+		atomic.CompareAndSwapInt64(v.tail, int64(len(v.elems)), int64(n)) {
+		return Vector{elems: append(v.elems, vals...), tail: v.tail}
+	}
+
+	res := make([]Value, len(v.elems), 2*n)
+	copy(res, v.elems)
+	return NewVector(append(res, vals...)...)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -59,7 +60,11 @@ func exec(filename string) error {
 	if err != nil {
 		return err
 	}
-	_, err = internal.Eval(string(data), internal.NewEnviroment())
+	_, err = internal.EvalFile(filename, string(data), internal.NewEnviroment())
+	if e, ok := err.(*internal.Error); ok {
+		// Include the functions that led to the error
+		return errors.New(e.Traceback())
+	}
 	return err
 }
 